@@ -0,0 +1,444 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+)
+
+// ChangeRule identifies a kind of change between two versions of a package.
+type ChangeRule string
+
+const (
+	RuleResourceRemoved     ChangeRule = "resource-removed"
+	RuleFunctionRemoved     ChangeRule = "function-removed"
+	RuleTypeRemoved         ChangeRule = "type-removed"
+	RuleModuleRenamed       ChangeRule = "module-renamed"
+	RuleInputRemoved        ChangeRule = "input-removed"
+	RuleOutputRemoved       ChangeRule = "output-removed"
+	RulePropertyRemoved     ChangeRule = "property-removed"
+	RulePropertyTypeChanged ChangeRule = "property-type-changed"
+	RuleInputRequired       ChangeRule = "input-required"
+	RuleIDChanged           ChangeRule = "id-changed"
+	RuleEnumValueRemoved    ChangeRule = "enum-value-removed"
+
+	RuleResourceAdded  ChangeRule = "resource-added"
+	RuleFunctionAdded  ChangeRule = "function-added"
+	RuleTypeAdded      ChangeRule = "type-added"
+	RuleInputAdded     ChangeRule = "input-added"
+	RuleOutputAdded    ChangeRule = "output-added"
+	RulePropertyAdded  ChangeRule = "property-added"
+	RuleInputOptional  ChangeRule = "input-optional"
+	RuleEnumValueAdded ChangeRule = "enum-value-added"
+)
+
+// Severity is how seriously a change is treated. Changes with SeverityError are breaking.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+	// SeverityIgnore leaves changes out of the report.
+	SeverityIgnore Severity = "ignore"
+)
+
+// DefaultSeverities are the severities of each rule unless overridden with BreakingOptionSeverity. Changes which
+// can break existing programs are errors, other changes are informational.
+var DefaultSeverities = map[ChangeRule]Severity{
+	RuleResourceRemoved:     SeverityError,
+	RuleFunctionRemoved:     SeverityError,
+	RuleTypeRemoved:         SeverityError,
+	RuleModuleRenamed:       SeverityError,
+	RuleInputRemoved:        SeverityError,
+	RuleOutputRemoved:       SeverityError,
+	RulePropertyRemoved:     SeverityError,
+	RulePropertyTypeChanged: SeverityError,
+	RuleInputRequired:       SeverityError,
+	RuleIDChanged:           SeverityError,
+	RuleEnumValueRemoved:    SeverityError,
+
+	RuleResourceAdded:  SeverityInfo,
+	RuleFunctionAdded:  SeverityInfo,
+	RuleTypeAdded:      SeverityInfo,
+	RuleInputAdded:     SeverityInfo,
+	RuleOutputAdded:    SeverityInfo,
+	RulePropertyAdded:  SeverityInfo,
+	RuleInputOptional:  SeverityInfo,
+	RuleEnumValueAdded: SeverityInfo,
+}
+
+// ClassifiedChange is a change between two versions of a package with the rule it matched.
+type ClassifiedChange struct {
+	Rule     ChangeRule `json:"rule"`
+	Severity Severity   `json:"severity"`
+	// Kind is one of "resources", "functions" or "types".
+	Kind  string `json:"kind"`
+	Token string `json:"token"`
+	// Path is the path of the changed field within the spec's JSON, or empty when the whole spec changed.
+	Path    FieldPath `json:"path,omitempty"`
+	Message string    `json:"message"`
+}
+
+// BreakingReport lists the classified changes between two versions of a package, sorted by kind, token and path.
+type BreakingReport struct {
+	Changes []ClassifiedChange `json:"changes"`
+}
+
+// Breaking reports whether any change has SeverityError.
+func (r *BreakingReport) Breaking() bool {
+	return slices.ContainsFunc(r.Changes, func(change ClassifiedChange) bool {
+		return change.Severity == SeverityError
+	})
+}
+
+// BreakingOptionSeverity overrides the severity of a rule.
+func BreakingOptionSeverity(rule ChangeRule, severity Severity) BreakingOption {
+	return breakingOptionFunc(func(opts *BreakingOptions) {
+		if opts.Severities == nil {
+			opts.Severities = map[ChangeRule]Severity{}
+		}
+		opts.Severities[rule] = severity
+	})
+}
+
+type BreakingOption interface {
+	Apply(*BreakingOptions)
+}
+
+type BreakingOptions struct {
+	// Severities overrides DefaultSeverities for individual rules.
+	Severities map[ChangeRule]Severity
+}
+
+type breakingOptionFunc func(*BreakingOptions)
+
+func (o breakingOptionFunc) Apply(opts *BreakingOptions) {
+	o(opts)
+}
+
+// DetectBreakingChanges compares two versions of a package and classifies each change to the package's resources,
+// functions and types, such as removed resources, changed property types or newly required inputs.
+func DetectBreakingChanges(ctx context.Context, oldPkg, newPkg PackageReader, opts ...BreakingOption) (*BreakingReport, error) {
+	options := &BreakingOptions{}
+	for _, opt := range opts {
+		opt.Apply(options)
+	}
+
+	oldResources, oldResourcesErr := oldPkg.GetResourcesContext(ctx)
+	newResources, newResourcesErr := newPkg.GetResourcesContext(ctx)
+	oldFunctions, oldFunctionsErr := oldPkg.GetFunctionsContext(ctx)
+	newFunctions, newFunctionsErr := newPkg.GetFunctionsContext(ctx)
+	oldTypes, oldTypesErr := oldPkg.GetTypesContext(ctx)
+	newTypes, newTypesErr := newPkg.GetTypesContext(ctx)
+	if err := errors.Join(oldResourcesErr, newResourcesErr, oldFunctionsErr, newFunctionsErr, oldTypesErr, newTypesErr); err != nil {
+		return nil, err
+	}
+
+	c := &changeClassifier{severities: options.Severities}
+	compareTokens(c, "resources", oldResources, newResources, RuleResourceRemoved, RuleResourceAdded)
+	for token, oldSpec := range oldResources {
+		if newSpec, ok := newResources[token]; ok {
+			c.compareResource(token, oldSpec, newSpec)
+		}
+	}
+	compareTokens(c, "functions", oldFunctions, newFunctions, RuleFunctionRemoved, RuleFunctionAdded)
+	for token, oldSpec := range oldFunctions {
+		if newSpec, ok := newFunctions[token]; ok {
+			c.compareFunction(token, oldSpec, newSpec)
+		}
+	}
+	compareTokens(c, "types", oldTypes, newTypes, RuleTypeRemoved, RuleTypeAdded)
+	for token, oldSpec := range oldTypes {
+		if newSpec, ok := newTypes[token]; ok {
+			c.compareType(token, oldSpec, newSpec)
+		}
+	}
+
+	kindOrder := map[string]int{"resources": 0, "functions": 1, "types": 2}
+	slices.SortFunc(c.changes, func(a, b ClassifiedChange) int {
+		if order := cmp.Compare(kindOrder[a.Kind], kindOrder[b.Kind]); order != 0 {
+			return order
+		}
+		if order := cmp.Compare(a.Token, b.Token); order != 0 {
+			return order
+		}
+		if order := slices.Compare(a.Path, b.Path); order != 0 {
+			return order
+		}
+		return cmp.Compare(a.Rule, b.Rule)
+	})
+	return &BreakingReport{Changes: c.changes}, nil
+}
+
+type changeClassifier struct {
+	severities map[ChangeRule]Severity
+	changes    []ClassifiedChange
+}
+
+func (c *changeClassifier) add(rule ChangeRule, kind, token string, path FieldPath, format string, args ...any) {
+	severity, ok := c.severities[rule]
+	if !ok {
+		severity = DefaultSeverities[rule]
+	}
+	if severity == SeverityIgnore {
+		return
+	}
+	c.changes = append(c.changes, ClassifiedChange{
+		Rule:     rule,
+		Severity: severity,
+		Kind:     kind,
+		Token:    token,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// compareTokens reports removed and added tokens of a kind. A token which was removed from one module and added to
+// another under the same name is reported as a renamed module instead.
+func compareTokens[T any](c *changeClassifier, kind string, oldSpecs, newSpecs map[string]T, removedRule, addedRule ChangeRule) {
+	removedByName := map[string][]string{}
+	addedByName := map[string][]string{}
+	for token := range oldSpecs {
+		if _, ok := newSpecs[token]; !ok {
+			removedByName[tokenName(token)] = append(removedByName[tokenName(token)], token)
+		}
+	}
+	for token := range newSpecs {
+		if _, ok := oldSpecs[token]; !ok {
+			addedByName[tokenName(token)] = append(addedByName[tokenName(token)], token)
+		}
+	}
+
+	for name, removed := range removedByName {
+		added := addedByName[name]
+		if name != "" && len(removed) == 1 && len(added) == 1 {
+			c.add(RuleModuleRenamed, kind, removed[0], nil, "%s %q moved to %q", kindName(kind), removed[0], added[0])
+			delete(addedByName, name)
+			continue
+		}
+		for _, token := range removed {
+			c.add(removedRule, kind, token, nil, "%s %q was removed", kindName(kind), token)
+		}
+	}
+	for _, added := range addedByName {
+		for _, token := range added {
+			c.add(addedRule, kind, token, nil, "%s %q was added", kindName(kind), token)
+		}
+	}
+}
+
+// tokenName returns the package and name of a token without its module, or an empty string for invalid tokens.
+func tokenName(token string) string {
+	t, err := tokens.ParseTypeToken(token)
+	if err != nil {
+		return ""
+	}
+	return t.Package().String() + ":" + t.Name().String()
+}
+
+func (c *changeClassifier) compareResource(token string, oldSpec, newSpec schema.ResourceSpec) {
+	const kind = "resources"
+	oldID, oldHasID := oldSpec.Properties["id"]
+	newID, newHasID := newSpec.Properties["id"]
+	switch {
+	case oldSpec.IsComponent != newSpec.IsComponent:
+		c.add(RuleIDChanged, kind, token, FieldPath{"isComponent"}, "resource %q changed from %s", token,
+			map[bool]string{true: "a component to a custom resource", false: "a custom resource to a component"}[oldSpec.IsComponent])
+	case oldHasID != newHasID || (oldHasID && !reflect.DeepEqual(oldID.TypeSpec, newID.TypeSpec)):
+		c.add(RuleIDChanged, kind, token, FieldPath{"properties", "id"}, "resource %q changed its id property", token)
+	}
+
+	c.compareInputs(kind, token, "inputProperties", oldSpec.InputProperties, oldSpec.RequiredInputs,
+		newSpec.InputProperties, newSpec.RequiredInputs)
+	c.compareOutputs(kind, token, "properties", withoutID(oldSpec.Properties), withoutID(newSpec.Properties))
+}
+
+func withoutID(props map[string]schema.PropertySpec) map[string]schema.PropertySpec {
+	if _, ok := props["id"]; !ok {
+		return props
+	}
+	propsCopy := make(map[string]schema.PropertySpec, len(props)-1)
+	for name, prop := range props {
+		if name != "id" {
+			propsCopy[name] = prop
+		}
+	}
+	return propsCopy
+}
+
+func (c *changeClassifier) compareFunction(token string, oldSpec, newSpec schema.FunctionSpec) {
+	const kind = "functions"
+	var oldInputs, newInputs schema.ObjectTypeSpec
+	if oldSpec.Inputs != nil {
+		oldInputs = *oldSpec.Inputs
+	}
+	if newSpec.Inputs != nil {
+		newInputs = *newSpec.Inputs
+	}
+	c.compareInputs(kind, token, "inputs", oldInputs.Properties, oldInputs.Required, newInputs.Properties, newInputs.Required)
+
+	oldOutputs, oldType := functionOutputs(oldSpec)
+	newOutputs, newType := functionOutputs(newSpec)
+	if oldType != nil || newType != nil {
+		if !reflect.DeepEqual(oldType, newType) {
+			c.add(RulePropertyTypeChanged, kind, token, FieldPath{"outputs"}, "function %q changed its return type from %s to %s",
+				token, describeReturnType(oldType), describeReturnType(newType))
+		}
+		return
+	}
+	c.compareOutputs(kind, token, "outputs", oldOutputs, newOutputs)
+}
+
+// functionOutputs returns either the output properties of a function or the type it returns.
+func functionOutputs(spec schema.FunctionSpec) (map[string]schema.PropertySpec, *schema.TypeSpec) {
+	if spec.ReturnType != nil {
+		if spec.ReturnType.TypeSpec != nil {
+			return nil, spec.ReturnType.TypeSpec
+		}
+		if spec.ReturnType.ObjectTypeSpec != nil {
+			return spec.ReturnType.ObjectTypeSpec.Properties, nil
+		}
+	}
+	if spec.Outputs != nil {
+		return spec.Outputs.Properties, nil
+	}
+	return nil, nil
+}
+
+func describeReturnType(spec *schema.TypeSpec) string {
+	if spec == nil {
+		return "an object"
+	}
+	return describeType(*spec)
+}
+
+func (c *changeClassifier) compareType(token string, oldSpec, newSpec schema.ComplexTypeSpec) {
+	const kind = "types"
+	if oldSpec.Type != newSpec.Type {
+		c.add(RulePropertyTypeChanged, kind, token, FieldPath{"type"}, "type %q changed from %s to %s",
+			token, oldSpec.Type, newSpec.Type)
+		return
+	}
+
+	if len(oldSpec.Enum) > 0 || len(newSpec.Enum) > 0 {
+		oldValues := enumValues(oldSpec.Enum)
+		newValues := enumValues(newSpec.Enum)
+		for _, value := range sortedKeys(oldValues) {
+			if _, ok := newValues[value]; !ok {
+				c.add(RuleEnumValueRemoved, kind, token, FieldPath{"enum", value}, "type %q no longer allows %s", token, value)
+			}
+		}
+		for _, value := range sortedKeys(newValues) {
+			if _, ok := oldValues[value]; !ok {
+				c.add(RuleEnumValueAdded, kind, token, FieldPath{"enum", value}, "type %q now allows %s", token, value)
+			}
+		}
+		return
+	}
+
+	// Object types can be used as both inputs and outputs, so removed properties are always breaking.
+	c.compareProperties(kind, token, "properties", oldSpec.Properties, oldSpec.Required, newSpec.Properties, newSpec.Required,
+		RulePropertyRemoved, RulePropertyAdded, "property")
+}
+
+// enumValues returns the enum's values keyed by their JSON representation.
+func enumValues(enum []schema.EnumValueSpec) map[string]struct{} {
+	values := make(map[string]struct{}, len(enum))
+	for _, value := range enum {
+		bytes, err := json.Marshal(value.Value)
+		if err != nil {
+			bytes = []byte(fmt.Sprint(value.Value))
+		}
+		values[string(bytes)] = struct{}{}
+	}
+	return values
+}
+
+func (c *changeClassifier) compareInputs(kind, token, section string, oldProps map[string]schema.PropertySpec, oldRequired []string,
+	newProps map[string]schema.PropertySpec, newRequired []string,
+) {
+	c.compareProperties(kind, token, section, oldProps, oldRequired, newProps, newRequired, RuleInputRemoved, RuleInputAdded, "input")
+}
+
+func (c *changeClassifier) compareOutputs(kind, token, section string, oldProps, newProps map[string]schema.PropertySpec) {
+	c.compareProperties(kind, token, section, oldProps, nil, newProps, nil, RuleOutputRemoved, RuleOutputAdded, "output")
+}
+
+// compareProperties reports removed, added and retyped properties. When required lists are given, it also reports
+// properties which became required or optional.
+func (c *changeClassifier) compareProperties(kind, token, section string, oldProps map[string]schema.PropertySpec, oldRequired []string,
+	newProps map[string]schema.PropertySpec, newRequired []string, removedRule, addedRule ChangeRule, noun string,
+) {
+	for _, name := range sortedKeys(oldProps) {
+		path := FieldPath{section, name}
+		newProp, ok := newProps[name]
+		if !ok {
+			c.add(removedRule, kind, token, path, "%s %q of %s %q was removed", noun, name, kindName(kind), token)
+			continue
+		}
+		if oldType, newType := oldProps[name].TypeSpec, newProp.TypeSpec; !reflect.DeepEqual(oldType, newType) {
+			c.add(RulePropertyTypeChanged, kind, token, path, "%s %q of %s %q %s",
+				noun, name, kindName(kind), token, describeTypeChange(oldType, newType))
+		}
+		wasRequired := slices.Contains(oldRequired, name)
+		isRequired := slices.Contains(newRequired, name)
+		switch {
+		case !wasRequired && isRequired:
+			c.add(RuleInputRequired, kind, token, path, "%s %q of %s %q is now required", noun, name, kindName(kind), token)
+		case wasRequired && !isRequired:
+			c.add(RuleInputOptional, kind, token, path, "%s %q of %s %q is now optional", noun, name, kindName(kind), token)
+		}
+	}
+	for _, name := range sortedKeys(newProps) {
+		if _, ok := oldProps[name]; ok {
+			continue
+		}
+		path := FieldPath{section, name}
+		if slices.Contains(newRequired, name) {
+			c.add(RuleInputRequired, kind, token, path, "required %s %q was added to %s %q", noun, name, kindName(kind), token)
+		} else {
+			c.add(addedRule, kind, token, path, "%s %q was added to %s %q", noun, name, kindName(kind), token)
+		}
+	}
+}
+
+func describeTypeChange(oldType, newType schema.TypeSpec) string {
+	oldDescription, newDescription := describeType(oldType), describeType(newType)
+	if oldDescription == newDescription {
+		return "changed type"
+	}
+	return fmt.Sprintf("changed type from %s to %s", oldDescription, newDescription)
+}
+
+// describeType returns a short description of a type, e.g. `array<string>` or `#/types/aws:ec2/Tag:Tag`.
+func describeType(spec schema.TypeSpec) string {
+	switch {
+	case spec.Ref != "":
+		return spec.Ref
+	case spec.Type == "array" && spec.Items != nil:
+		return "array<" + describeType(*spec.Items) + ">"
+	case spec.Type == "object" && spec.AdditionalProperties != nil:
+		return "map<" + describeType(*spec.AdditionalProperties) + ">"
+	case len(spec.OneOf) > 0:
+		types := make([]string, len(spec.OneOf))
+		for i, t := range spec.OneOf {
+			types[i] = describeType(t)
+		}
+		return "union<" + strings.Join(types, ", ") + ">"
+	case spec.Type == "":
+		return "any"
+	default:
+		return spec.Type
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/pulumi/splitschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stringProperty() schema.PropertySpec {
+	return schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+}
+
+func breakingTestPackages() (oldPkg, newPkg *schema.PackageSpec) {
+	oldPkg = &schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Removed": {},
+			"test:old:Moved":     {},
+			"test:index:Changed": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{
+						"id":      stringProperty(),
+						"arn":     stringProperty(),
+						"removed": stringProperty(),
+					},
+				},
+				InputProperties: map[string]schema.PropertySpec{
+					"size":     stringProperty(),
+					"name":     stringProperty(),
+					"optional": stringProperty(),
+					"dropped":  stringProperty(),
+				},
+				RequiredInputs: []string{"optional"},
+			},
+		},
+		Functions: map[string]schema.FunctionSpec{
+			"test:index:getThing": {
+				Inputs: &schema.ObjectTypeSpec{Properties: map[string]schema.PropertySpec{"id": stringProperty()}},
+			},
+		},
+		Types: map[string]schema.ComplexTypeSpec{
+			"test:index:Size": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{Type: "string"},
+				Enum:           []schema.EnumValueSpec{{Value: "small"}, {Value: "large"}},
+			},
+		},
+	}
+	newPkg = &schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Added": {},
+			"test:new:Moved":   {},
+			"test:index:Changed": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{
+						"id":    {TypeSpec: schema.TypeSpec{Type: "integer"}},
+						"arn":   stringProperty(),
+						"added": stringProperty(),
+					},
+				},
+				InputProperties: map[string]schema.PropertySpec{
+					"size":     {TypeSpec: schema.TypeSpec{Type: "array", Items: &schema.TypeSpec{Type: "string"}}},
+					"name":     stringProperty(),
+					"optional": stringProperty(),
+					"new":      stringProperty(),
+				},
+				RequiredInputs: []string{"name"},
+			},
+		},
+		Functions: map[string]schema.FunctionSpec{
+			"test:index:getThing": {
+				Inputs: &schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{"id": stringProperty(), "region": stringProperty()},
+					Required:   []string{"region"},
+				},
+			},
+		},
+		Types: map[string]schema.ComplexTypeSpec{
+			"test:index:Size": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{Type: "string"},
+				Enum:           []schema.EnumValueSpec{{Value: "small"}, {Value: "medium"}},
+			},
+		},
+	}
+	return oldPkg, newPkg
+}
+
+func TestDetectBreakingChanges(t *testing.T) {
+	oldPkg, newPkg := breakingTestPackages()
+	oldDir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(oldDir, oldPkg))
+
+	report, err := splitschema.DetectBreakingChanges(context.Background(),
+		splitschema.NewLocalPartialPackage(oldDir), splitschema.NewPackageReader(newPkg))
+	require.NoError(t, err)
+	assert.True(t, report.Breaking())
+
+	type change struct {
+		rule  splitschema.ChangeRule
+		token string
+		path  string
+	}
+	var changes []change
+	for _, c := range report.Changes {
+		changes = append(changes, change{c.Rule, c.Token, c.Path.String()})
+	}
+	assert.Equal(t, []change{
+		{splitschema.RuleResourceAdded, "test:index:Added", ""},
+		{splitschema.RuleInputRemoved, "test:index:Changed", "inputProperties.dropped"},
+		{splitschema.RuleInputRequired, "test:index:Changed", "inputProperties.name"},
+		{splitschema.RuleInputAdded, "test:index:Changed", "inputProperties.new"},
+		{splitschema.RuleInputOptional, "test:index:Changed", "inputProperties.optional"},
+		{splitschema.RulePropertyTypeChanged, "test:index:Changed", "inputProperties.size"},
+		{splitschema.RuleOutputAdded, "test:index:Changed", "properties.added"},
+		{splitschema.RuleIDChanged, "test:index:Changed", "properties.id"},
+		{splitschema.RuleOutputRemoved, "test:index:Changed", "properties.removed"},
+		{splitschema.RuleResourceRemoved, "test:index:Removed", ""},
+		{splitschema.RuleModuleRenamed, "test:old:Moved", ""},
+		{splitschema.RuleInputRequired, "test:index:getThing", "inputs.region"},
+		{splitschema.RuleEnumValueRemoved, "test:index:Size", `enum."large"`},
+		{splitschema.RuleEnumValueAdded, "test:index:Size", `enum."medium"`},
+	}, changes)
+	assert.Equal(t, `input "size" of resource "test:index:Changed" changed type from string to array<string>`,
+		report.Changes[5].Message)
+	assert.Equal(t, `resource "test:old:Moved" moved to "test:new:Moved"`, report.Changes[10].Message)
+}
+
+func TestBreakingSeverity(t *testing.T) {
+	oldPkg := &schema.PackageSpec{Name: "test", Resources: map[string]schema.ResourceSpec{"test:index:Removed": {}}}
+	newPkg := &schema.PackageSpec{Name: "test", Resources: map[string]schema.ResourceSpec{"test:index:Added": {}}}
+
+	report, err := splitschema.DetectBreakingChanges(context.Background(),
+		splitschema.NewPackageReader(oldPkg), splitschema.NewPackageReader(newPkg),
+		splitschema.BreakingOptionSeverity(splitschema.RuleResourceRemoved, splitschema.SeverityWarning),
+		splitschema.BreakingOptionSeverity(splitschema.RuleResourceAdded, splitschema.SeverityIgnore))
+	require.NoError(t, err)
+	assert.False(t, report.Breaking())
+	require.Len(t, report.Changes, 1)
+	assert.Equal(t, splitschema.RuleResourceRemoved, report.Changes[0].Rule)
+	assert.Equal(t, splitschema.SeverityWarning, report.Changes[0].Severity)
+
+	report, err = splitschema.DetectBreakingChanges(context.Background(),
+		splitschema.NewPackageReader(oldPkg), splitschema.NewPackageReader(oldPkg))
+	require.NoError(t, err)
+	assert.False(t, report.Breaking())
+	assert.Empty(t, report.Changes)
+}
@@ -0,0 +1,201 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+)
+
+// Changelog summarises the user-facing changes between two versions of a package, grouped by module.
+type Changelog struct {
+	// Modules lists the modules with changes, sorted by name.
+	Modules []ModuleChanges `json:"modules"`
+}
+
+// ModuleChanges lists the changes within a single module. Each list is sorted by token, and then by property.
+type ModuleChanges struct {
+	// Module is the first segment of the module of each token, e.g. "ec2" for `aws:ec2/instance:Instance`.
+	Module           string        `json:"module"`
+	NewResources     []string      `json:"newResources,omitempty"`
+	NewFunctions     []string      `json:"newFunctions,omitempty"`
+	Deprecations     []Deprecation `json:"deprecations,omitempty"`
+	RemovedResources []string      `json:"removedResources,omitempty"`
+	RemovedFunctions []string      `json:"removedFunctions,omitempty"`
+	NewProperties    []NewProperty `json:"newProperties,omitempty"`
+}
+
+// Deprecation is a resource, function or property which has been newly deprecated.
+type Deprecation struct {
+	Token string `json:"token"`
+	// Property is the name of the deprecated property, or empty if the whole resource or function is deprecated.
+	Property string `json:"property,omitempty"`
+	Message  string `json:"message"`
+}
+
+// NewProperty is a property added to an existing resource or function.
+type NewProperty struct {
+	Token    string `json:"token"`
+	Property string `json:"property"`
+	Input    bool   `json:"input,omitempty"`
+	Output   bool   `json:"output,omitempty"`
+}
+
+// GenerateChangelog lists the new, deprecated and removed resources and functions between two versions of a package,
+// along with properties added to existing resources and functions.
+func GenerateChangelog(ctx context.Context, oldPkg, newPkg PackageReader) (*Changelog, error) {
+	// Load the specs through each reader, so its concurrency limit applies and tokens which only have metadata are left
+	// out.
+	oldResources, oldResourcesErr := oldPkg.GetResourcesContext(ctx)
+	newResources, newResourcesErr := newPkg.GetResourcesContext(ctx)
+	oldFunctions, oldFunctionsErr := oldPkg.GetFunctionsContext(ctx)
+	newFunctions, newFunctionsErr := newPkg.GetFunctionsContext(ctx)
+	if err := errors.Join(oldResourcesErr, newResourcesErr, oldFunctionsErr, newFunctionsErr); err != nil {
+		return nil, err
+	}
+	oldResourceTokens, newResourceTokens := sortedKeys(oldResources), sortedKeys(newResources)
+	oldFunctionTokens, newFunctionTokens := sortedKeys(oldFunctions), sortedKeys(newFunctions)
+
+	modules := map[string]*ModuleChanges{}
+	module := func(token string) *ModuleChanges {
+		name := ""
+		if modName, _, err := splitToken(token, false); err == nil {
+			name = modName
+		}
+		if modules[name] == nil {
+			modules[name] = &ModuleChanges{Module: name}
+		}
+		return modules[name]
+	}
+
+	for _, token := range newResourceTokens {
+		if !containsSorted(oldResourceTokens, token) {
+			module(token).NewResources = append(module(token).NewResources, token)
+		}
+	}
+	for _, token := range oldResourceTokens {
+		if !containsSorted(newResourceTokens, token) {
+			module(token).RemovedResources = append(module(token).RemovedResources, token)
+		}
+	}
+	for _, token := range newFunctionTokens {
+		if !containsSorted(oldFunctionTokens, token) {
+			module(token).NewFunctions = append(module(token).NewFunctions, token)
+		}
+	}
+	for _, token := range oldFunctionTokens {
+		if !containsSorted(newFunctionTokens, token) {
+			module(token).RemovedFunctions = append(module(token).RemovedFunctions, token)
+		}
+	}
+
+	// Only specs in both versions can have deprecations or new properties.
+	commonResources := intersect(oldResourceTokens, newResourceTokens)
+	commonFunctions := intersect(oldFunctionTokens, newFunctionTokens)
+	for _, token := range commonResources {
+		oldSpec, newSpec := oldResources[token], newResources[token]
+		changes := module(token)
+		if oldSpec.DeprecationMessage == "" && newSpec.DeprecationMessage != "" {
+			changes.Deprecations = append(changes.Deprecations, Deprecation{Token: token, Message: newSpec.DeprecationMessage})
+		}
+		changes.addProperties(token, oldSpec.InputProperties, newSpec.InputProperties, oldSpec.Properties, newSpec.Properties)
+	}
+	for _, token := range commonFunctions {
+		oldSpec, newSpec := oldFunctions[token], newFunctions[token]
+		changes := module(token)
+		if oldSpec.DeprecationMessage == "" && newSpec.DeprecationMessage != "" {
+			changes.Deprecations = append(changes.Deprecations, Deprecation{Token: token, Message: newSpec.DeprecationMessage})
+		}
+		oldOutputs, _ := functionOutputs(oldSpec)
+		newOutputs, _ := functionOutputs(newSpec)
+		changes.addProperties(token, functionInputs(oldSpec), functionInputs(newSpec), oldOutputs, newOutputs)
+	}
+
+	changelog := &Changelog{}
+	for _, name := range sortedKeys(modules) {
+		changes := modules[name]
+		if changes.empty() {
+			continue
+		}
+		// Resources and functions in the same module are interleaved by token.
+		slices.SortFunc(changes.Deprecations, func(a, b Deprecation) int {
+			return compareTokenProperty(a.Token, a.Property, b.Token, b.Property)
+		})
+		slices.SortFunc(changes.NewProperties, func(a, b NewProperty) int {
+			return compareTokenProperty(a.Token, a.Property, b.Token, b.Property)
+		})
+		changelog.Modules = append(changelog.Modules, *changes)
+	}
+	return changelog, nil
+}
+
+// addProperties records the inputs and outputs added to a spec, and any existing properties which were deprecated.
+func (m *ModuleChanges) addProperties(token string, oldInputs, newInputs, oldOutputs, newOutputs map[string]schema.PropertySpec) {
+	names := sortedKeys(newInputs)
+	for name := range newOutputs {
+		if _, ok := newInputs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		newInput, isInput := newInputs[name]
+		newOutput, isOutput := newOutputs[name]
+		oldInput, wasInput := oldInputs[name]
+		oldOutput, wasOutput := oldOutputs[name]
+
+		addedInput := isInput && !wasInput
+		addedOutput := isOutput && !wasOutput
+		if addedInput || addedOutput {
+			m.NewProperties = append(m.NewProperties, NewProperty{Token: token, Property: name, Input: addedInput, Output: addedOutput})
+		}
+
+		switch {
+		case wasInput && oldInput.DeprecationMessage == "" && newInput.DeprecationMessage != "":
+			m.Deprecations = append(m.Deprecations, Deprecation{Token: token, Property: name, Message: newInput.DeprecationMessage})
+		case wasOutput && oldOutput.DeprecationMessage == "" && newOutput.DeprecationMessage != "":
+			m.Deprecations = append(m.Deprecations, Deprecation{Token: token, Property: name, Message: newOutput.DeprecationMessage})
+		}
+	}
+}
+
+// compareTokenProperty orders changes by token, then by property, with changes to the whole spec first.
+func compareTokenProperty(aToken, aProperty, bToken, bProperty string) int {
+	if c := strings.Compare(aToken, bToken); c != 0 {
+		return c
+	}
+	return strings.Compare(aProperty, bProperty)
+}
+
+func (m *ModuleChanges) empty() bool {
+	return len(m.NewResources) == 0 && len(m.NewFunctions) == 0 && len(m.Deprecations) == 0 &&
+		len(m.RemovedResources) == 0 && len(m.RemovedFunctions) == 0 && len(m.NewProperties) == 0
+}
+
+func functionInputs(spec schema.FunctionSpec) map[string]schema.PropertySpec {
+	if spec.Inputs == nil {
+		return nil
+	}
+	return spec.Inputs.Properties
+}
+
+// intersect returns the tokens in both sorted lists.
+func intersect(a, b []string) []string {
+	var both []string
+	for _, token := range a {
+		if containsSorted(b, token) {
+			both = append(both, token)
+		}
+	}
+	return both
+}
+
+func containsSorted(tokens []string, token string) bool {
+	_, ok := slices.BinarySearch(tokens, token)
+	return ok
+}
@@ -0,0 +1,75 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/pulumi/splitschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateChangelog(t *testing.T) {
+	oldPkg := &schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:ec2/instance:Instance": {
+				ObjectTypeSpec:  schema.ObjectTypeSpec{Properties: map[string]schema.PropertySpec{"arn": stringProperty()}},
+				InputProperties: map[string]schema.PropertySpec{"ami": stringProperty()},
+			},
+			"test:s3/bucket:Bucket": {},
+		},
+		Functions: map[string]schema.FunctionSpec{
+			"test:ec2/getAmi:getAmi": {},
+		},
+	}
+	newPkg := &schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:ec2/instance:Instance": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{Properties: map[string]schema.PropertySpec{
+					"arn":      stringProperty(),
+					"userData": stringProperty(),
+				}},
+				InputProperties: map[string]schema.PropertySpec{
+					"ami":      {TypeSpec: schema.TypeSpec{Type: "string"}, DeprecationMessage: "Use image instead"},
+					"userData": stringProperty(),
+				},
+			},
+			"test:ec2/vpc:Vpc": {},
+		},
+		Functions: map[string]schema.FunctionSpec{
+			"test:ec2/getAmi:getAmi":     {DeprecationMessage: "Use getImage instead"},
+			"test:ec2/getImage:getImage": {},
+		},
+	}
+	// Tokens which only have metadata aren't in the package, so the VPC is still new.
+	oldMetadata := &splitschema.PackageMetadata{Resources: map[string]any{"test:ec2/vpc:Vpc": "meta"}}
+	oldDir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpecWithMetadata(oldDir, oldPkg, oldMetadata))
+
+	changelog, err := splitschema.GenerateChangelog(context.Background(),
+		splitschema.NewLocalPartialPackage(oldDir), splitschema.NewPackageReader(newPkg))
+	require.NoError(t, err)
+	assert.Equal(t, &splitschema.Changelog{Modules: []splitschema.ModuleChanges{
+		{
+			Module:       "ec2",
+			NewResources: []string{"test:ec2/vpc:Vpc"},
+			NewFunctions: []string{"test:ec2/getImage:getImage"},
+			Deprecations: []splitschema.Deprecation{
+				{Token: "test:ec2/getAmi:getAmi", Message: "Use getImage instead"},
+				{Token: "test:ec2/instance:Instance", Property: "ami", Message: "Use image instead"},
+			},
+			NewProperties: []splitschema.NewProperty{
+				{Token: "test:ec2/instance:Instance", Property: "userData", Input: true, Output: true},
+			},
+		},
+		{
+			Module:           "s3",
+			RemovedResources: []string{"test:s3/bucket:Bucket"},
+		},
+	}}, changelog)
+}
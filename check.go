@@ -0,0 +1,98 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+)
+
+// CheckPackageSpec reports whether the split directory at path is up to date with pkg. It returns the paths, relative
+// to path and sorted, of any files which writing pkg with the same options and pruning would add, change or remove.
+// The tool version recorded in the manifest is ignored so directories written by other versions can be checked.
+func CheckPackageSpec(path string, pkg *schema.PackageSpec, opts ...WriteOption) ([]string, error) {
+	tempDir, err := os.MkdirTemp("", "splitschema-check-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	expectedPath := filepath.Join(tempDir, "expected")
+	if err := WritePackageSpec(expectedPath, pkg, opts...); err != nil {
+		return nil, err
+	}
+
+	expected, err := readLayoutFiles(expectedPath)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := readLayoutFiles(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	var outOfDate []string
+	for relPath, expectedBytes := range expected {
+		actualBytes, ok := actual[relPath]
+		if !ok || !filesEqual(relPath, expectedBytes, actualBytes) {
+			outOfDate = append(outOfDate, relPath)
+		}
+	}
+	for relPath := range actual {
+		if _, ok := expected[relPath]; !ok {
+			outOfDate = append(outOfDate, relPath)
+		}
+	}
+	slices.Sort(outOfDate)
+	return outOfDate, nil
+}
+
+// readLayoutFiles reads all files belonging to the split layout in a directory, as recorded by its index files, keyed
+// by their slash-separated path relative to the directory.
+func readLayoutFiles(root string) (map[string][]byte, error) {
+	layoutFiles := indexedLayoutFiles(os.DirFS(root), ".")
+	files := map[string][]byte{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if !layoutFiles.contains(relPath) {
+			return nil
+		}
+		bytes, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[relPath] = bytes
+		return nil
+	})
+	return files, err
+}
+
+func filesEqual(relPath string, expected, actual []byte) bool {
+	if relPath != manifestFileName {
+		return bytes.Equal(expected, actual)
+	}
+	var expectedManifest, actualManifest Manifest
+	if json.Unmarshal(expected, &expectedManifest) != nil || json.Unmarshal(actual, &actualManifest) != nil {
+		return bytes.Equal(expected, actual)
+	}
+	expectedManifest.ToolVersion = ""
+	actualManifest.ToolVersion = ""
+	return expectedManifest == actualManifest
+}
@@ -0,0 +1,95 @@
+// Copyright 2024, Pulumi Corporation.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pulumi/splitschema"
+	"github.com/spf13/cobra"
+)
+
+var (
+	breakingOutput     string
+	breakingSeverities map[string]string
+)
+
+var breakingCmd = &cobra.Command{
+	Use:   "breaking old new",
+	Short: "Check for breaking changes between two versions of a schema",
+	Long: `Check for breaking changes between two versions of a schema, such as removed
+resources, changed property types or newly required inputs. Each version can be a
+schema JSON file or a split directory. Exits with a non-zero status if any change
+has the error severity.`,
+	Args: cobra.ExactArgs(2),
+	// A failed check isn't a usage error.
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts []splitschema.BreakingOption
+		for rule, severity := range breakingSeverities {
+			if _, ok := splitschema.DefaultSeverities[splitschema.ChangeRule(rule)]; !ok {
+				return fmt.Errorf("unknown rule: %s", rule)
+			}
+			switch splitschema.Severity(severity) {
+			case splitschema.SeverityError, splitschema.SeverityWarning, splitschema.SeverityInfo, splitschema.SeverityIgnore:
+			default:
+				return fmt.Errorf("unknown severity for %s: %s", rule, severity)
+			}
+			opts = append(opts, splitschema.BreakingOptionSeverity(splitschema.ChangeRule(rule), splitschema.Severity(severity)))
+		}
+
+		oldPkg, err := splitschema.OpenPackageReader(args[0])
+		if err != nil {
+			return fmt.Errorf("open %s: %w", args[0], err)
+		}
+		newPkg, err := splitschema.OpenPackageReader(args[1])
+		if err != nil {
+			return fmt.Errorf("open %s: %w", args[1], err)
+		}
+		report, err := splitschema.DetectBreakingChanges(cmd.Context(), oldPkg, newPkg, opts...)
+		if err != nil {
+			return fmt.Errorf("compare schemas: %w", err)
+		}
+
+		out := cmd.OutOrStdout()
+		switch breakingOutput {
+		case "human":
+			writeHumanBreaking(out, report)
+		case "json":
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(report); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported output: %s", breakingOutput)
+		}
+		if report.Breaking() {
+			return fmt.Errorf("breaking changes found")
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(breakingCmd)
+	breakingCmd.Flags().StringVarP(&breakingOutput, "output", "o", "human", "Output format (human or json)")
+	breakingCmd.Flags().StringToStringVar(&breakingSeverities, "severity", nil,
+		"Override the severity of a rule, e.g. --severity output-removed=warning (error, warning, info or ignore)")
+}
+
+func writeHumanBreaking(out io.Writer, report *splitschema.BreakingReport) {
+	if len(report.Changes) == 0 {
+		fmt.Fprintln(out, "No changes")
+		return
+	}
+	counts := map[splitschema.Severity]int{}
+	for _, change := range report.Changes {
+		fmt.Fprintf(out, "%-7s %s: %s\n", change.Severity, change.Rule, change.Message)
+		counts[change.Severity]++
+	}
+	fmt.Fprintf(out, "\n%d errors, %d warnings, %d info\n",
+		counts[splitschema.SeverityError], counts[splitschema.SeverityWarning], counts[splitschema.SeverityInfo])
+}
@@ -0,0 +1,117 @@
+// Copyright 2024, Pulumi Corporation.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pulumi/splitschema"
+	"github.com/spf13/cobra"
+)
+
+var (
+	changelogFrom string
+	changelogTo   string
+)
+
+var changelogCmd = &cobra.Command{
+	Use:   "changelog",
+	Short: "Generate a markdown changelog between two versions of a schema",
+	Long: `Generate a markdown changelog between two versions of a schema, grouped by module,
+listing new resources and functions, deprecations, removed items and new properties.
+Each version can be a schema JSON file or a split directory.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		oldPkg, err := splitschema.OpenPackageReader(changelogFrom)
+		if err != nil {
+			return fmt.Errorf("open %s: %w", changelogFrom, err)
+		}
+		newPkg, err := splitschema.OpenPackageReader(changelogTo)
+		if err != nil {
+			return fmt.Errorf("open %s: %w", changelogTo, err)
+		}
+		changelog, err := splitschema.GenerateChangelog(cmd.Context(), oldPkg, newPkg)
+		if err != nil {
+			return fmt.Errorf("generate changelog: %w", err)
+		}
+		writeMarkdownChangelog(cmd.OutOrStdout(), changelog)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(changelogCmd)
+	changelogCmd.Flags().StringVar(&changelogFrom, "from", "", "Previous version of the schema (file or split directory)")
+	changelogCmd.Flags().StringVar(&changelogTo, "to", "", "New version of the schema (file or split directory)")
+	_ = changelogCmd.MarkFlagRequired("from")
+	_ = changelogCmd.MarkFlagRequired("to")
+}
+
+func writeMarkdownChangelog(out io.Writer, changelog *splitschema.Changelog) {
+	if len(changelog.Modules) == 0 {
+		fmt.Fprintln(out, "No changes.")
+		return
+	}
+	for i, module := range changelog.Modules {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		name := module.Module
+		if name == "" {
+			name = "Other"
+		}
+		fmt.Fprintf(out, "## %s\n", name)
+
+		writeTokenList(out, "New resources", module.NewResources)
+		writeTokenList(out, "New functions", module.NewFunctions)
+		if len(module.Deprecations) > 0 {
+			fmt.Fprint(out, "\n### Deprecations\n\n")
+			for _, deprecation := range module.Deprecations {
+				item := "`" + deprecation.Token + "`"
+				if deprecation.Property != "" {
+					item += " property `" + deprecation.Property + "`"
+				}
+				fmt.Fprintf(out, "- %s: %s\n", item, singleLine(deprecation.Message))
+			}
+		}
+		if len(module.RemovedResources) > 0 || len(module.RemovedFunctions) > 0 {
+			fmt.Fprint(out, "\n### Removed\n\n")
+			for _, token := range module.RemovedResources {
+				fmt.Fprintf(out, "- Resource `%s`\n", token)
+			}
+			for _, token := range module.RemovedFunctions {
+				fmt.Fprintf(out, "- Function `%s`\n", token)
+			}
+		}
+		if len(module.NewProperties) > 0 {
+			fmt.Fprint(out, "\n### New properties\n\n")
+			for _, property := range module.NewProperties {
+				var kinds []string
+				if property.Input {
+					kinds = append(kinds, "input")
+				}
+				if property.Output {
+					kinds = append(kinds, "output")
+				}
+				fmt.Fprintf(out, "- `%s`: `%s` (%s)\n", property.Token, property.Property, strings.Join(kinds, ", "))
+			}
+		}
+	}
+}
+
+func writeTokenList(out io.Writer, title string, tokens []string) {
+	if len(tokens) == 0 {
+		return
+	}
+	fmt.Fprintf(out, "\n### %s\n\n", title)
+	for _, token := range tokens {
+		fmt.Fprintf(out, "- `%s`\n", token)
+	}
+}
+
+// singleLine joins a multi-line message so it fits in a list item.
+func singleLine(message string) string {
+	return strings.Join(strings.Fields(message), " ")
+}
@@ -0,0 +1,78 @@
+// Copyright 2024, Pulumi Corporation.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pulumi/splitschema"
+	"github.com/spf13/cobra"
+)
+
+var checkRefsOutput string
+
+var checkRefsCmd = &cobra.Command{
+	Use:   "check-refs dir",
+	Short: "Check the references between the specs of a split directory",
+	Long: `Check the local $refs (e.g. "#/types/aws:ec2/Tag:Tag") in every resource, function
+and type of a split directory, listing references to types or resources which don't
+exist, types which aren't used by any resource or function, and types which reference
+each other in a cycle. Exits with a non-zero status if there are any dangling references;
+unused types and cycles are only reported.`,
+	Args: cobra.ExactArgs(1),
+	// A failed check isn't a usage error.
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pkg := splitschema.NewLocalPartialPackage(args[0])
+		report, err := pkg.CheckReferences(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("check references: %w", err)
+		}
+
+		out := cmd.OutOrStdout()
+		switch checkRefsOutput {
+		case "human":
+			writeHumanReferences(out, report)
+		case "json":
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(report); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported output: %s", checkRefsOutput)
+		}
+		if len(report.Dangling) > 0 {
+			return fmt.Errorf("%d dangling references found", len(report.Dangling))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkRefsCmd)
+	checkRefsCmd.Flags().StringVarP(&checkRefsOutput, "output", "o", "human", "Output format (human or json)")
+}
+
+func writeHumanReferences(out io.Writer, report *splitschema.ReferenceReport) {
+	if report.Empty() {
+		fmt.Fprintln(out, "No problems found")
+		return
+	}
+	for _, ref := range report.Dangling {
+		spec := ref.Kind
+		if ref.Token != "" {
+			spec = ref.Token
+		}
+		fmt.Fprintf(out, "dangling %s %s: %s\n", spec, ref.Path, ref.Ref)
+	}
+	for _, token := range report.UnusedTypes {
+		fmt.Fprintf(out, "unused   %s\n", token)
+	}
+	for _, cycle := range report.Cycles {
+		fmt.Fprintf(out, "cycle    %s\n", strings.Join(cycle, ", "))
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2024, Pulumi Corporation.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pulumi/splitschema"
+	"github.com/spf13/cobra"
+)
+
+var diffOutput string
+
+var diffCmd = &cobra.Command{
+	Use:   "diff old new",
+	Short: "Compare two versions of a schema",
+	Long: `Compare two versions of a schema, reporting added, removed and changed resources,
+functions and types down to individual fields. Each version can be a schema JSON
+file or a split directory.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		diff, err := diffPackages(cmd, args[0], args[1])
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+		switch diffOutput {
+		case "human":
+			writeHumanDiff(out, diff)
+		case "json":
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(diff)
+		case "markdown":
+			writeMarkdownDiff(out, diff)
+		default:
+			return fmt.Errorf("unsupported output: %s", diffOutput)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", "human", "Output format (human, json or markdown)")
+}
+
+// diffPackages opens and compares two versions of a schema, each either a schema JSON file or a split directory.
+func diffPackages(cmd *cobra.Command, oldPath, newPath string) (*splitschema.PackageDiff, error) {
+	oldPkg, err := splitschema.OpenPackageReader(oldPath)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", oldPath, err)
+	}
+	newPkg, err := splitschema.OpenPackageReader(newPath)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", newPath, err)
+	}
+	diff, err := splitschema.DiffPackages(cmd.Context(), oldPkg, newPkg)
+	if err != nil {
+		return nil, fmt.Errorf("compare schemas: %w", err)
+	}
+	return diff, nil
+}
+
+var changeSymbols = map[splitschema.ChangeKind]string{
+	splitschema.ChangeAdded:   "+",
+	splitschema.ChangeRemoved: "-",
+	splitschema.ChangeChanged: "~",
+}
+
+func writeHumanDiff(out io.Writer, diff *splitschema.PackageDiff) {
+	if diff.Empty() {
+		fmt.Fprintln(out, "No changes")
+		return
+	}
+	for _, section := range diffSections(diff) {
+		if len(section.diffs) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "%s:\n", section.title)
+		for _, specDiff := range section.diffs {
+			fmt.Fprintf(out, "  %s %s\n", changeSymbols[specDiff.Change], specDiff.Token)
+			for _, field := range specDiff.Fields {
+				fmt.Fprintf(out, "      %s %s%s\n", changeSymbols[field.Change], field.Path, formatFieldChange(field, humanValue))
+			}
+		}
+	}
+}
+
+func writeMarkdownDiff(out io.Writer, diff *splitschema.PackageDiff) {
+	if diff.Empty() {
+		fmt.Fprintln(out, "No changes.")
+		return
+	}
+	first := true
+	for _, section := range diffSections(diff) {
+		if len(section.diffs) == 0 {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(out)
+		}
+		first = false
+		fmt.Fprintf(out, "## %s\n\n", section.title)
+		for _, specDiff := range section.diffs {
+			fmt.Fprintf(out, "- %s `%s`\n", changeTitle(specDiff.Change), specDiff.Token)
+			for _, field := range specDiff.Fields {
+				fmt.Fprintf(out, "  - %s `%s`%s\n", changeTitle(field.Change), field.Path, formatFieldChange(field, markdownValue))
+			}
+		}
+	}
+}
+
+type diffSection struct {
+	title string
+	diffs []splitschema.SpecDiff
+}
+
+func diffSections(diff *splitschema.PackageDiff) []diffSection {
+	return []diffSection{
+		{"Resources", diff.Resources},
+		{"Functions", diff.Functions},
+		{"Types", diff.Types},
+	}
+}
+
+func changeTitle(change splitschema.ChangeKind) string {
+	return strings.ToUpper(string(change[:1])) + string(change[1:])
+}
+
+// formatFieldChange describes the values of a changed field, e.g. `: "string" → "integer"`. Long values are truncated
+// around the first difference between the old and new values, so the change stays visible.
+func formatFieldChange(field splitschema.FieldChange, quote func(string) string) string {
+	switch field.Change {
+	case splitschema.ChangeAdded:
+		return ": " + quote(truncateValue([]rune(formatValue(field.New)), 0))
+	case splitschema.ChangeChanged:
+		oldValue, newValue := []rune(formatValue(field.Old)), []rune(formatValue(field.New))
+		start := 0
+		for start < len(oldValue) && start < len(newValue) && oldValue[start] == newValue[start] {
+			start++
+		}
+		return ": " + quote(truncateValue(oldValue, start)) + " → " + quote(truncateValue(newValue, start))
+	default:
+		return ""
+	}
+}
+
+// maxValueLength is the length at which values are truncated, so long descriptions don't swamp the output.
+const maxValueLength = 60
+
+// valueContext is the number of characters kept before the first difference when a value is truncated.
+const valueContext = 20
+
+func formatValue(value any) string {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(bytes)
+}
+
+// truncateValue shortens a formatted value to maxValueLength characters, starting shortly before diffStart, marking
+// the parts which were cut with an ellipsis.
+func truncateValue(value []rune, diffStart int) string {
+	if len(value) <= maxValueLength {
+		return string(value)
+	}
+	start := max(0, min(diffStart-valueContext, len(value)-maxValueLength))
+	end := start + maxValueLength
+	s := string(value[start:end])
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(value) {
+		s += "…"
+	}
+	return s
+}
+
+// markdownValue formats a value as inline code.
+func markdownValue(s string) string {
+	if strings.Contains(s, "`") {
+		return "`` " + s + " ``"
+	}
+	return "`" + s + "`"
+}
+
+// humanValue formats a value as it is.
+func humanValue(s string) string {
+	return s
+}
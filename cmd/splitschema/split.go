@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 
 	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
 	"github.com/pulumi/splitschema"
@@ -15,6 +16,14 @@ import (
 var (
 	splitSource string
 	splitDest   string
+	splitFormat string
+	splitPrune  bool
+	splitLayout string
+	splitCheck  bool
+
+	splitPropertyDescriptions bool
+	splitExamples             bool
+	splitDescriptions         string
 )
 
 var splitCmd = &cobra.Command{
@@ -22,6 +31,8 @@ var splitCmd = &cobra.Command{
 	Short: "Split a schema file into component files",
 	Long: `Split a schema file into component files. This command will read a schema file
 and split it into separate files for each resource, provider, and type.`,
+	// An out of date directory found by --check isn't a usage error.
+	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		pkgBytes, err := os.ReadFile(splitSource)
 		if err != nil {
@@ -32,12 +43,45 @@ and split it into separate files for each resource, provider, and type.`,
 		if err != nil {
 			return fmt.Errorf("unmarshal source package spec: %w", err)
 		}
+		opts := []splitschema.WriteOption{splitschema.WriteOptionFormat(splitFormat)}
+		if splitPrune {
+			opts = append(opts, splitschema.WriteOptionPrune())
+		}
+		layout, ok := splitschema.LookupPathLayout(splitLayout)
+		if !ok {
+			return fmt.Errorf("unknown layout: %s", splitLayout)
+		}
+		opts = append(opts, splitschema.WriteOptionPathLayout(layout))
+		if splitPropertyDescriptions {
+			opts = append(opts, splitschema.WriteOptionPropertyDescriptions())
+		}
+		descriptions, err := parseDescriptionRule(splitDescriptions)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, splitschema.WriteOptionDescriptions(descriptions))
+		if splitExamples {
+			opts = append(opts, splitschema.WriteOptionExamples())
+		}
+		if splitCheck {
+			outOfDate, err := splitschema.CheckPackageSpec(splitDest, &pkg, opts...)
+			if err != nil {
+				return fmt.Errorf("check split package spec: %w", err)
+			}
+			if len(outOfDate) > 0 {
+				for _, path := range outOfDate {
+					fmt.Fprintln(cmd.ErrOrStderr(), path)
+				}
+				return fmt.Errorf("%s is out of date: %d files differ", splitDest, len(outOfDate))
+			}
+			return nil
+		}
 		// Ensure destination exists
 		err = os.MkdirAll(splitDest, 0755)
 		if err != nil {
 			return fmt.Errorf("create destination directory: %w", err)
 		}
-		err = splitschema.WritePackageSpec(splitDest, &pkg)
+		err = splitschema.WritePackageSpec(splitDest, &pkg, opts...)
 		if err != nil {
 			return fmt.Errorf("write split package spec: %w", err)
 		}
@@ -45,8 +89,32 @@ and split it into separate files for each resource, provider, and type.`,
 	},
 }
 
+// parseDescriptionRule parses the value of the --descriptions flag.
+func parseDescriptionRule(value string) (splitschema.DescriptionRule, error) {
+	switch value {
+	case "multiline":
+		return splitschema.MultiLineDescriptions, nil
+	case "always":
+		return splitschema.AllDescriptions, nil
+	case "never":
+		return splitschema.NoDescriptions, nil
+	}
+	length, err := strconv.Atoi(value)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid --descriptions %q: expected multiline, always, never or a length", value)
+	}
+	return splitschema.DescriptionsLongerThan(length), nil
+}
+
 func init() {
 	rootCmd.AddCommand(splitCmd)
 	splitCmd.Flags().StringVarP(&splitSource, "source", "s", ".", "Source schema file to split")
 	splitCmd.Flags().StringVarP(&splitDest, "dest", "d", "schema.json", "Destination directory to write split schema")
+	splitCmd.Flags().StringVarP(&splitFormat, "format", "f", "json", "Format of the split files (json or yaml)")
+	splitCmd.Flags().BoolVar(&splitPrune, "prune", true, "Remove stale files left over from a previous split")
+	splitCmd.Flags().StringVar(&splitLayout, "layout", "hashed", "Layout of the split files (hashed, nested-modules, flat or module-sharded)")
+	splitCmd.Flags().BoolVar(&splitPropertyDescriptions, "property-descriptions", false, "Write multi-line property descriptions to separate markdown files")
+	splitCmd.Flags().BoolVar(&splitExamples, "examples", false, "Write the code of examples in descriptions to separate files per example and language")
+	splitCmd.Flags().StringVar(&splitDescriptions, "descriptions", "multiline", "Which descriptions to write to markdown files (multiline, always, never, or a length in characters)")
+	splitCmd.Flags().BoolVar(&splitCheck, "check", false, "Check the destination is up to date without writing, failing if any files differ")
 }
@@ -0,0 +1,41 @@
+// Copyright 2024, Pulumi Corporation.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/pulumi/splitschema"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate dir",
+	Short: "Check a split directory is internally consistent",
+	Long: `Check a split directory is internally consistent: every token in the index files
+has a spec file, every spec, description and metadata file is referenced by an index,
+the core file contains no resources, functions or types, and every file can be decoded.
+All problems are listed, and the command exits with a non-zero status if there are any.`,
+	Args: cobra.ExactArgs(1),
+	// A failed check isn't a usage error.
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := splitschema.ValidateLocalPackage(args[0])
+		if err == nil {
+			fmt.Fprintln(cmd.OutOrStdout(), "No problems found")
+			return nil
+		}
+		problems := []error{err}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			problems = joined.Unwrap()
+		}
+		for _, problem := range problems {
+			fmt.Fprintln(cmd.OutOrStdout(), problem)
+		}
+		return fmt.Errorf("%d problems found", len(problems))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
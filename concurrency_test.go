@@ -0,0 +1,296 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema_test
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/pulumi/splitschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run with -race to detect data races between readers.
+
+const concurrency = 32
+
+func writeConcurrencyPackage(t *testing.T) (string, *schema.PackageSpec) {
+	pkg := &schema.PackageSpec{
+		Name:      "test",
+		Resources: map[string]schema.ResourceSpec{},
+		Functions: map[string]schema.FunctionSpec{},
+		Types:     map[string]schema.ComplexTypeSpec{},
+	}
+	for i := 0; i < 50; i++ {
+		pkg.Resources[fmt.Sprintf("test:mod%d:Resource%d", i%5, i)] = schema.ResourceSpec{
+			ObjectTypeSpec: schema.ObjectTypeSpec{Description: fmt.Sprintf("Resource %d\nsecond line", i)},
+		}
+		pkg.Functions[fmt.Sprintf("test:mod%d:getThing%d", i%5, i)] = schema.FunctionSpec{
+			Description: fmt.Sprintf("Function %d", i),
+		}
+		pkg.Types[fmt.Sprintf("test:mod%d:Type%d", i%5, i)] = schema.ComplexTypeSpec{
+			ObjectTypeSpec: schema.ObjectTypeSpec{Type: "object", Description: fmt.Sprintf("Type %d", i)},
+		}
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, pkg))
+	return dir, pkg
+}
+
+// hammer runs fn from many goroutines at once and waits for them all to finish.
+func hammer(fn func(i int)) {
+	var waitGroup sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < concurrency; i++ {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+			<-start
+			fn(i)
+		}(i)
+	}
+	close(start)
+	waitGroup.Wait()
+}
+
+func TestConcurrentReadPackageSpec(t *testing.T) {
+	dir, pkg := writeConcurrencyPackage(t)
+	partialPkg := splitschema.NewLocalPartialPackage(dir)
+
+	specs := make([]*schema.PackageSpec, concurrency)
+	errs := make([]error, concurrency)
+	hammer(func(i int) {
+		specs[i], errs[i] = partialPkg.ReadPackageSpec()
+		if errs[i] == nil {
+			// The top level of the returned spec is a copy, so modifying it must not affect other callers.
+			specs[i].Name = fmt.Sprintf("modified-%d", i)
+			specs[i].Resources[fmt.Sprintf("test:index:Added%d", i)] = schema.ResourceSpec{}
+		}
+	})
+	for i := range specs {
+		require.NoError(t, errs[i])
+		assert.Len(t, specs[i].Resources, len(pkg.Resources)+1)
+		delete(specs[i].Resources, fmt.Sprintf("test:index:Added%d", i))
+		assert.Equal(t, pkg.Resources, specs[i].Resources)
+		assert.Equal(t, pkg.Functions, specs[i].Functions)
+		assert.Equal(t, pkg.Types, specs[i].Types)
+	}
+
+	readSpec, err := partialPkg.ReadPackageSpec()
+	require.NoError(t, err)
+	assert.Equal(t, "test", readSpec.Name)
+	assert.Equal(t, pkg.Resources, readSpec.Resources)
+}
+
+func TestConcurrentGetters(t *testing.T) {
+	dir, pkg := writeConcurrencyPackage(t)
+	partialPkg := splitschema.NewLocalPartialPackage(dir)
+
+	hammer(func(i int) {
+		switch i % 6 {
+		case 0:
+			resources, err := partialPkg.GetResources()
+			assert.NoError(t, err)
+			assert.Len(t, resources, len(pkg.Resources))
+		case 1:
+			functions, err := partialPkg.GetFunctions()
+			assert.NoError(t, err)
+			assert.Len(t, functions, len(pkg.Functions))
+		case 2:
+			types, err := partialPkg.GetTypes()
+			assert.NoError(t, err)
+			assert.Len(t, types, len(pkg.Types))
+		case 3:
+			for token, expected := range pkg.Resources {
+				actual, err := partialPkg.GetResource(token)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, *actual)
+			}
+		case 4:
+			for token, expected := range pkg.Functions {
+				actual, err := partialPkg.GetFunction(token)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, *actual)
+			}
+		case 5:
+			for token, expected := range pkg.Types {
+				actual, err := partialPkg.GetType(token)
+				assert.NoError(t, err)
+				assert.Equal(t, expected, *actual)
+			}
+		}
+	})
+}
+
+func TestConcurrentMetadata(t *testing.T) {
+	token := "test:index:Resource"
+	pkg := schema.PackageSpec{
+		Name:      "test",
+		Resources: map[string]schema.ResourceSpec{token: {}},
+	}
+	metadata := splitschema.PackageMetadata{
+		Resources: map[string]any{token: map[string]any{"key": "value"}},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpecWithMetadata(dir, &pkg, &metadata))
+	partialPkg := splitschema.NewLocalPartialPackageWithMetadata[map[string]any, any, any](dir)
+
+	results := make([]*map[string]any, concurrency)
+	hammer(func(i int) {
+		var err error
+		results[i], err = partialPkg.GetResourceMeta(token)
+		assert.NoError(t, err)
+	})
+	for _, result := range results {
+		// All goroutines must observe the same cached value.
+		assert.Same(t, results[0], result)
+	}
+}
+
+func TestConcurrentErrorsAreAggregated(t *testing.T) {
+	dir, _ := writeConcurrencyPackage(t)
+	// Corrupt a few resource files so loading all resources fails in several places at once.
+	corrupted := 0
+	require.NoError(t, filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Base(filepath.Dir(path)) != "resources" || filepath.Ext(path) != ".json" {
+			return err
+		}
+		if corrupted < 3 {
+			corrupted++
+			return os.WriteFile(path, []byte("{not json"), 0644)
+		}
+		return nil
+	}))
+	require.Equal(t, 3, corrupted)
+
+	partialPkg := splitschema.NewLocalPartialPackage(dir)
+	hammer(func(i int) {
+		_, err := partialPkg.ReadPackageSpec()
+		// ReadPackageSpec joins the resource, function and type errors, and the resource error joins each failure.
+		joined, ok := err.(interface{ Unwrap() []error })
+		if !assert.True(t, ok, "expected joined errors") {
+			return
+		}
+		resourceErrs, ok := joined.Unwrap()[0].(interface{ Unwrap() []error })
+		if assert.True(t, ok, "expected joined resource errors") {
+			assert.Len(t, resourceErrs.Unwrap(), 3)
+		}
+	})
+}
+
+// countingFS tracks the total number of files opened and the maximum number of files open at once. Each open waits
+// for delay while counted as open, so concurrent reads overlap.
+type countingFS struct {
+	fs.FS
+	delay   time.Duration
+	total   atomic.Int64
+	open    atomic.Int64
+	maxOpen atomic.Int64
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	f, err := c.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	c.total.Add(1)
+	open := c.open.Add(1)
+	for {
+		maxOpen := c.maxOpen.Load()
+		if open <= maxOpen || c.maxOpen.CompareAndSwap(maxOpen, open) {
+			break
+		}
+	}
+	time.Sleep(c.delay)
+	return &countingFile{File: f, fs: c}, nil
+}
+
+type countingFile struct {
+	fs.File
+	fs *countingFS
+}
+
+func (f *countingFile) Close() error {
+	f.fs.open.Add(-1)
+	return f.File.Close()
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	dir, pkg := writeConcurrencyPackage(t)
+	fsys := &countingFS{FS: os.DirFS(dir)}
+	partialPkg := splitschema.NewPartialPackage(fsys, ".", splitschema.ReadOptionConcurrency(2))
+
+	resources, err := partialPkg.GetResources()
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources, resources)
+	assert.LessOrEqual(t, fsys.maxOpen.Load(), int64(2))
+
+	// Reading the whole package loads resources, functions and types at the same time, within the same limit.
+	fsys = &countingFS{FS: os.DirFS(dir), delay: time.Millisecond}
+	partialPkg = splitschema.NewPartialPackage(fsys, ".", splitschema.ReadOptionConcurrency(2))
+	spec, err := partialPkg.ReadPackageSpec()
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Functions, spec.Functions)
+	assert.Equal(t, pkg.Types, spec.Types)
+	assert.LessOrEqual(t, fsys.maxOpen.Load(), int64(2))
+}
+
+func TestCancelledContext(t *testing.T) {
+	dir, _ := writeConcurrencyPackage(t)
+	partialPkg := splitschema.NewLocalPartialPackage(dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := partialPkg.ReadPackageSpecContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = partialPkg.GetResourcesContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = partialPkg.GetResourceContext(ctx, "test:mod0:Resource0")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = partialPkg.GetFunctionContext(ctx, "test:mod0:getThing0")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = partialPkg.GetTypeContext(ctx, "test:mod0:Type0")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// cancellingFS cancels a context once a number of files have been opened.
+type cancellingFS struct {
+	fs.FS
+	opened atomic.Int64
+	after  int64
+	cancel context.CancelFunc
+}
+
+func (c *cancellingFS) Open(name string) (fs.File, error) {
+	if c.opened.Add(1) == c.after {
+		c.cancel()
+	}
+	return c.FS.Open(name)
+}
+
+func TestCancelStopsReading(t *testing.T) {
+	dir, pkg := writeConcurrencyPackage(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fsys := &cancellingFS{FS: os.DirFS(dir), after: 10, cancel: cancel}
+	partialPkg := splitschema.NewPartialPackage(fsys, ".", splitschema.ReadOptionConcurrency(2))
+
+	_, err := partialPkg.GetResourcesContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	// Each resource reads a description and a spec file, so a full read would open many more files.
+	assert.Less(t, fsys.opened.Load(), int64(2*len(pkg.Resources)))
+
+	// The package remains usable with a fresh context.
+	resources, err := partialPkg.GetResourcesContext(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources, resources)
+}
@@ -0,0 +1,172 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ChangeKind describes how a spec or field changed between two versions of a package.
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeChanged ChangeKind = "changed"
+)
+
+// PackageDiff describes the differences between two versions of a package. Each list is sorted by token.
+type PackageDiff struct {
+	Resources []SpecDiff `json:"resources,omitempty"`
+	Functions []SpecDiff `json:"functions,omitempty"`
+	Types     []SpecDiff `json:"types,omitempty"`
+}
+
+// Empty reports whether the two versions of the package have the same resources, functions and types.
+func (d *PackageDiff) Empty() bool {
+	return len(d.Resources) == 0 && len(d.Functions) == 0 && len(d.Types) == 0
+}
+
+// SpecDiff describes how a single resource, function or type changed.
+type SpecDiff struct {
+	Token  string     `json:"token"`
+	Change ChangeKind `json:"change"`
+	// Fields lists the changed fields of a changed spec, sorted by path.
+	Fields []FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange describes a change to a single field of a spec.
+type FieldChange struct {
+	// Path is the path of the field within the spec's JSON, e.g. ["inputProperties", "userData", "type"].
+	Path   FieldPath  `json:"path"`
+	Change ChangeKind `json:"change"`
+	// Old is the JSON value of the field before the change, or nil if it was added.
+	Old any `json:"old,omitempty"`
+	// New is the JSON value of the field after the change, or nil if it was removed.
+	New any `json:"new,omitempty"`
+}
+
+// DiffPackages compares two versions of a package, reporting which resources, functions and types were added,
+// removed or changed, and which fields of the changed specs differ. Either package can be a split directory or a
+// package in memory.
+func DiffPackages(ctx context.Context, oldPkg, newPkg PackageReader) (*PackageDiff, error) {
+	resources, resourcesErr := diffSpecs(ctx, oldPkg.GetResourcesContext, newPkg.GetResourcesContext)
+	functions, functionsErr := diffSpecs(ctx, oldPkg.GetFunctionsContext, newPkg.GetFunctionsContext)
+	types, typesErr := diffSpecs(ctx, oldPkg.GetTypesContext, newPkg.GetTypesContext)
+	if err := errors.Join(resourcesErr, functionsErr, typesErr); err != nil {
+		return nil, err
+	}
+	return &PackageDiff{Resources: resources, Functions: functions, Types: types}, nil
+}
+
+func diffSpecs[T any](ctx context.Context, getOld, getNew func(ctx context.Context) (map[string]T, error)) ([]SpecDiff, error) {
+	oldSpecs, err := getOld(ctx)
+	if err != nil {
+		return nil, err
+	}
+	newSpecs, err := getNew(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := sortedKeys(oldSpecs)
+	for token := range newSpecs {
+		if _, ok := oldSpecs[token]; !ok {
+			tokens = append(tokens, token)
+		}
+	}
+	slices.Sort(tokens)
+
+	var diffs []SpecDiff
+	for _, token := range tokens {
+		oldSpec, inOld := oldSpecs[token]
+		newSpec, inNew := newSpecs[token]
+		switch {
+		case !inOld:
+			diffs = append(diffs, SpecDiff{Token: token, Change: ChangeAdded})
+		case !inNew:
+			diffs = append(diffs, SpecDiff{Token: token, Change: ChangeRemoved})
+		default:
+			fields, err := diffFields(oldSpec, newSpec)
+			if err != nil {
+				return nil, err
+			}
+			if len(fields) > 0 {
+				diffs = append(diffs, SpecDiff{Token: token, Change: ChangeChanged, Fields: fields})
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// diffFields compares the JSON representation of two specs, so fields are named as they appear in the schema.
+func diffFields(oldSpec, newSpec any) ([]FieldChange, error) {
+	oldValue, err := jsonValue(oldSpec)
+	if err != nil {
+		return nil, err
+	}
+	newValue, err := jsonValue(newSpec)
+	if err != nil {
+		return nil, err
+	}
+	var changes []FieldChange
+	diffValues(nil, oldValue, newValue, &changes)
+	return changes, nil
+}
+
+func jsonValue(data any) (any, error) {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	err = json.Unmarshal(bytes, &value)
+	return value, err
+}
+
+// diffValues appends the changes between two JSON values to changes, recursing into objects. Arrays are compared as
+// a whole.
+func diffValues(path FieldPath, oldValue, newValue any, changes *[]FieldChange) {
+	oldObject, oldIsObject := oldValue.(map[string]any)
+	newObject, newIsObject := newValue.(map[string]any)
+	if !oldIsObject || !newIsObject {
+		if !reflect.DeepEqual(oldValue, newValue) {
+			*changes = append(*changes, FieldChange{Path: path, Change: ChangeChanged, Old: oldValue, New: newValue})
+		}
+		return
+	}
+
+	keys := sortedKeys(oldObject)
+	for key := range newObject {
+		if _, ok := oldObject[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		keyPath := append(slices.Clip(path), key)
+		oldField, inOld := oldObject[key]
+		newField, inNew := newObject[key]
+		switch {
+		case !inOld:
+			*changes = append(*changes, FieldChange{Path: keyPath, Change: ChangeAdded, New: newField})
+		case !inNew:
+			*changes = append(*changes, FieldChange{Path: keyPath, Change: ChangeRemoved, Old: oldField})
+		default:
+			diffValues(keyPath, oldField, newField, changes)
+		}
+	}
+}
+
+// FieldPath is the path of a field within a spec's JSON.
+type FieldPath []string
+
+// String returns the path joined with dots, e.g. `inputProperties.userData.type`.
+func (p FieldPath) String() string {
+	return strings.Join(p, ".")
+}
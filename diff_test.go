@@ -0,0 +1,116 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/pulumi/splitschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diffTestPackages() (oldPkg, newPkg *schema.PackageSpec) {
+	oldPkg = &schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Changed": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Unchanged"},
+				InputProperties: map[string]schema.PropertySpec{
+					"size":    {TypeSpec: schema.TypeSpec{Type: "string"}},
+					"removed": {TypeSpec: schema.TypeSpec{Type: "string"}},
+				},
+			},
+			"test:index:Removed":   {},
+			"test:index:Unchanged": {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Unchanged"}},
+		},
+		Functions: map[string]schema.FunctionSpec{
+			"test:index:getThing": {Description: "Before"},
+		},
+	}
+	newPkg = &schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Added": {},
+			"test:index:Changed": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Unchanged"},
+				InputProperties: map[string]schema.PropertySpec{
+					"size":  {TypeSpec: schema.TypeSpec{Type: "integer"}},
+					"added": {TypeSpec: schema.TypeSpec{Type: "string"}},
+				},
+				RequiredInputs: []string{"size"},
+			},
+			"test:index:Unchanged": {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Unchanged"}},
+		},
+		Functions: map[string]schema.FunctionSpec{
+			"test:index:getThing": {Description: "After"},
+		},
+	}
+	return oldPkg, newPkg
+}
+
+func TestDiffPackages(t *testing.T) {
+	oldPkg, newPkg := diffTestPackages()
+	oldDir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(oldDir, oldPkg))
+
+	// Compare a split directory with a package in memory.
+	diff, err := splitschema.DiffPackages(context.Background(),
+		splitschema.NewLocalPartialPackage(oldDir), splitschema.NewPackageReader(newPkg))
+	require.NoError(t, err)
+	assert.Equal(t, &splitschema.PackageDiff{
+		Resources: []splitschema.SpecDiff{
+			{Token: "test:index:Added", Change: splitschema.ChangeAdded},
+			{Token: "test:index:Changed", Change: splitschema.ChangeChanged, Fields: []splitschema.FieldChange{
+				{Path: splitschema.FieldPath{"inputProperties", "added"}, Change: splitschema.ChangeAdded, New: map[string]any{"type": "string"}},
+				{Path: splitschema.FieldPath{"inputProperties", "removed"}, Change: splitschema.ChangeRemoved, Old: map[string]any{"type": "string"}},
+				{Path: splitschema.FieldPath{"inputProperties", "size", "type"}, Change: splitschema.ChangeChanged, Old: "string", New: "integer"},
+				{Path: splitschema.FieldPath{"requiredInputs"}, Change: splitschema.ChangeAdded, New: []any{"size"}},
+			}},
+			{Token: "test:index:Removed", Change: splitschema.ChangeRemoved},
+		},
+		Functions: []splitschema.SpecDiff{
+			{Token: "test:index:getThing", Change: splitschema.ChangeChanged, Fields: []splitschema.FieldChange{
+				{Path: splitschema.FieldPath{"description"}, Change: splitschema.ChangeChanged, Old: "Before", New: "After"},
+			}},
+		},
+	}, diff)
+	assert.Equal(t, "inputProperties.size.type", diff.Resources[1].Fields[2].Path.String())
+
+	diff, err = splitschema.DiffPackages(context.Background(),
+		splitschema.NewLocalPartialPackage(oldDir), splitschema.NewPackageReader(oldPkg))
+	require.NoError(t, err)
+	assert.True(t, diff.Empty())
+}
+
+func TestOpenPackageReader(t *testing.T) {
+	pkg, _ := diffTestPackages()
+	dir := t.TempDir()
+	splitDir := filepath.Join(dir, "split")
+	require.NoError(t, splitschema.WritePackageSpec(splitDir, pkg))
+	schemaPath := filepath.Join(dir, "schema.json")
+	bytes, err := json.Marshal(pkg)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(schemaPath, bytes, 0644))
+
+	for _, path := range []string{splitDir, schemaPath} {
+		reader, err := splitschema.OpenPackageReader(path)
+		require.NoError(t, err)
+		tokens, err := reader.GetResourceTokens()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"test:index:Changed", "test:index:Removed", "test:index:Unchanged"}, tokens)
+		readSpec, err := reader.ReadPackageSpec()
+		require.NoError(t, err)
+		assert.Equal(t, pkg.Resources, readSpec.Resources)
+		_, err = reader.GetResource("test:index:Missing")
+		assert.ErrorIs(t, err, splitschema.ErrTokenNotFound)
+	}
+
+	_, err = splitschema.OpenPackageReader(filepath.Join(dir, "missing.json"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
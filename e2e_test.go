@@ -3,11 +3,22 @@
 package splitschema_test
 
 import (
+	"bytes"
 	"embed"
 	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
 	"github.com/pulumi/splitschema"
@@ -212,3 +223,1199 @@ func TestMetadataRoundTrip(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, metadata.Resources[token], *actual)
 }
+
+type functionMeta struct {
+	Endpoint string `json:"endpoint"`
+}
+
+type typeMeta struct {
+	Fields []string `json:"fields"`
+}
+
+func TestTypedMetadataRoundTrip(t *testing.T) {
+	resourceToken := "test:index:Resource"
+	functionToken := "test:index:getThing"
+	typeToken := "test:index:Thing"
+	pkg := schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			resourceToken: {InputProperties: map[string]schema.PropertySpec{"foo": {TypeSpec: schema.TypeSpec{Type: "string"}}}},
+		},
+		Functions: map[string]schema.FunctionSpec{
+			functionToken: {Description: "Gets a thing."},
+		},
+		Types: map[string]schema.ComplexTypeSpec{
+			typeToken: {ObjectTypeSpec: schema.ObjectTypeSpec{Type: "object", Description: "A thing."}},
+		},
+	}
+	metadata := splitschema.TypedPackageMetadata[map[string]any, functionMeta, typeMeta]{
+		Resources: map[string]map[string]any{resourceToken: {"metaKey": "metaValue"}},
+		Functions: map[string]functionMeta{functionToken: {Endpoint: "/things/{id}"}},
+		Types:     map[string]typeMeta{typeToken: {Fields: []string{"a", "b"}}},
+	}
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, splitschema.WritePackageSpecWithTypedMetadata(dir, &pkg, &metadata, splitschema.WriteOptionFormat(format)))
+
+			readPkg := splitschema.NewLocalPartialPackageWithMetadata[map[string]any, functionMeta, typeMeta](dir)
+			resourceMeta, err := readPkg.GetResourceMeta(resourceToken)
+			require.NoError(t, err)
+			assert.Equal(t, metadata.Resources[resourceToken], *resourceMeta)
+			fnMeta, err := readPkg.GetFunctionMeta(functionToken)
+			require.NoError(t, err)
+			assert.Equal(t, metadata.Functions[functionToken], *fnMeta)
+			tyMeta, err := readPkg.GetTypeMeta(typeToken)
+			require.NoError(t, err)
+			assert.Equal(t, metadata.Types[typeToken], *tyMeta)
+
+			// Metadata must not overwrite the specs themselves.
+			readSpec, err := readPkg.ReadPackageSpec()
+			require.NoError(t, err)
+			assert.Equal(t, pkg.Resources, readSpec.Resources)
+			assert.Equal(t, pkg.Functions, readSpec.Functions)
+			assert.Equal(t, pkg.Types, readSpec.Types)
+		})
+	}
+}
+
+func TestLanguageRoundTrip(t *testing.T) {
+	// Raw language blobs must come back with the same key order and number formatting. JSON files indent them, so
+	// they're compared compacted.
+	compact := func(raw schema.RawMessage) string {
+		var buf bytes.Buffer
+		require.NoError(t, json.Compact(&buf, raw))
+		return buf.String()
+	}
+	pkg := schema.PackageSpec{
+		Name: "test",
+		Language: map[string]schema.RawMessage{
+			"nodejs": schema.RawMessage(`{"b":1,"a":[1,2.50],"c":{"z":null,"y":true,"x":"1e3"},"d":1e3}`),
+		},
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Resource": {ObjectTypeSpec: schema.ObjectTypeSpec{
+				Language: map[string]schema.RawMessage{"csharp": schema.RawMessage(`{"name":"Res","flags":[-0.10,3]}`)},
+			}},
+		},
+	}
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, splitschema.WritePackageSpec(dir, &pkg, splitschema.WriteOptionFormat(format)))
+
+			readSpec, err := splitschema.ReadPackageSpec(dir)
+			require.NoError(t, err)
+			assert.Equal(t, compact(pkg.Language["nodejs"]), compact(readSpec.Language["nodejs"]))
+			assert.Equal(t, compact(pkg.Resources["test:index:Resource"].Language["csharp"]),
+				compact(readSpec.Resources["test:index:Resource"].Language["csharp"]))
+		})
+	}
+}
+
+func TestAwsYamlRoundTrip(t *testing.T) {
+	pkg, err := readPackage(filepath.Join("testdata", "aws.json"))
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	err = splitschema.WritePackageSpec(dir, pkg, splitschema.WriteOptionFormat("yaml"))
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "core.yaml"))
+	assert.NoFileExists(t, filepath.Join(dir, "core.json"))
+
+	readSpec, err := splitschema.ReadPackageSpec(dir)
+	require.NoError(t, err)
+	assert.Equal(t, pkg, readSpec)
+}
+
+func TestManifest(t *testing.T) {
+	pkg := schema.PackageSpec{Name: "test"}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg, splitschema.WriteOptionFormat("yaml"), splitschema.WriteOptionCompact()))
+
+	manifest, err := splitschema.ReadManifest(os.DirFS(dir), ".")
+	require.NoError(t, err)
+	// Directories which don't use any optional files are readable by readers which only support version 2.
+	assert.Equal(t, 2, manifest.LayoutVersion)
+	assert.Equal(t, "yaml", manifest.Format)
+	assert.Equal(t, "crc32c", manifest.HashAlgorithm)
+	// Test binaries aren't releases, so no version is recorded which would change with every build.
+	assert.Empty(t, manifest.ToolVersion)
+	assert.True(t, manifest.Compact)
+
+	readSpec, err := splitschema.ReadPackageSpec(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "test", readSpec.Name)
+}
+
+func TestManifestFutureLayout(t *testing.T) {
+	pkg := schema.PackageSpec{Name: "test"}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg))
+	manifest := fmt.Sprintf(`{"layoutVersion": %d, "format": "json", "hashAlgorithm": "crc32c"}`, splitschema.LayoutVersion+1)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(manifest), 0644))
+
+	_, err := splitschema.ReadPackageSpec(dir)
+	assert.ErrorContains(t, err, "newer than the supported version")
+}
+
+func TestManifestMissing(t *testing.T) {
+	pkg := schema.PackageSpec{Name: "test"}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg))
+	require.NoError(t, os.Remove(filepath.Join(dir, "manifest.json")))
+
+	readSpec, err := splitschema.ReadPackageSpec(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "test", readSpec.Name)
+}
+
+func TestPrune(t *testing.T) {
+	pkg := schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Kept":    {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Kept\nresource"}},
+			"test:other:Removed": {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Removed\nresource"}},
+		},
+	}
+	metadata := splitschema.PackageMetadata{
+		Resources: map[string]any{"test:other:Removed": map[string]any{"key": "value"}},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpecWithMetadata(dir, &pkg, &metadata))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme"), 0644))
+	// Files which weren't written by the layout are kept, even in directories named after a kind.
+	userFiles := []string{filepath.Join("docs", "types", "notes.md"), filepath.Join("other", "resources", "notes.md")}
+	for _, userFile := range userFiles {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, userFile)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, userFile), []byte("notes"), 0644))
+	}
+	assert.DirExists(t, filepath.Join(dir, "other"))
+
+	delete(pkg.Resources, "test:other:Removed")
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg, splitschema.WriteOptionFormat("yaml"), splitschema.WriteOptionPrune()))
+
+	assert.NoFileExists(t, filepath.Join(dir, "other", "resources", "removed-"+shortHash("Removed")+".json"))
+	assert.NoFileExists(t, filepath.Join(dir, "other", "resources", "removed-"+shortHash("Removed")+".md"))
+	assert.NoFileExists(t, filepath.Join(dir, "other", "resources", "removed-"+shortHash("Removed")+".meta.json"))
+	for _, userFile := range userFiles {
+		assert.FileExists(t, filepath.Join(dir, userFile))
+	}
+	assert.NoFileExists(t, filepath.Join(dir, "core.json"))
+	assert.NoFileExists(t, filepath.Join(dir, "resources.json"))
+	assert.FileExists(t, filepath.Join(dir, "README.md"))
+	assert.FileExists(t, filepath.Join(dir, "manifest.json"))
+
+	readSpec, err := splitschema.ReadPackageSpec(dir)
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources, readSpec.Resources)
+}
+
+func TestFailedWriteLeavesDestinationUntouched(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "schema")
+	pkg := schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Resource": {},
+		},
+	}
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg))
+	coreBefore, err := os.ReadFile(filepath.Join(dir, "core.json"))
+	require.NoError(t, err)
+
+	badPkg := schema.PackageSpec{
+		Name: "changed",
+		Resources: map[string]schema.ResourceSpec{
+			"not-a-token": {},
+		},
+	}
+	assert.Error(t, splitschema.WritePackageSpec(dir, &badPkg, splitschema.WriteOptionPrune()))
+
+	coreAfter, err := os.ReadFile(filepath.Join(dir, "core.json"))
+	require.NoError(t, err)
+	assert.Equal(t, coreBefore, coreAfter)
+	entries, err := os.ReadDir(parent)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "staging directory should be cleaned up")
+
+	readSpec, err := splitschema.ReadPackageSpec(dir)
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources, readSpec.Resources)
+}
+
+func TestStagedWriteKeepsExistingEntries(t *testing.T) {
+	parent := t.TempDir()
+	target := filepath.Join(parent, "target")
+	pkg := schema.PackageSpec{
+		Name:      "test",
+		Resources: map[string]schema.ResourceSpec{"test:index:Resource": {}},
+	}
+	require.NoError(t, splitschema.WritePackageSpec(target, &pkg))
+	require.NoError(t, os.Chmod(target, 0750))
+	require.NoError(t, os.Chmod(filepath.Join(target, "index"), 0700))
+	require.NoError(t, os.MkdirAll(filepath.Join(target, ".git", "objects"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(target, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(target, "empty"), 0711))
+	gitInfo, err := os.Stat(filepath.Join(target, ".git", "HEAD"))
+	require.NoError(t, err)
+	link := filepath.Join(parent, "link")
+	require.NoError(t, os.Symlink(target, link))
+
+	pkg.Resources["test:index:Added"] = schema.ResourceSpec{}
+	require.NoError(t, splitschema.WritePackageSpec(link, &pkg, splitschema.WriteOptionPrune()))
+
+	linkInfo, err := os.Lstat(link)
+	require.NoError(t, err)
+	assert.True(t, linkInfo.Mode()&fs.ModeSymlink != 0, "symlinked destination should be kept")
+	assert.FileExists(t, filepath.Join(target, "index", "resources", "added-"+shortHash("Added")+".json"))
+
+	movedInfo, err := os.Stat(filepath.Join(target, ".git", "HEAD"))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(gitInfo, movedInfo), "existing entries should be moved rather than copied")
+	assert.DirExists(t, filepath.Join(target, ".git", "objects"))
+	for dir, mode := range map[string]fs.FileMode{".": 0750, "index": 0700, "empty": 0711} {
+		info, err := os.Stat(filepath.Join(target, dir))
+		require.NoError(t, err)
+		assert.Equal(t, mode, info.Mode().Perm(), dir)
+	}
+	entries, err := os.ReadDir(parent)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "staging directories should be cleaned up")
+}
+
+func TestStagedWriteSwapsEntriesOfMountPoints(t *testing.T) {
+	parent := t.TempDir()
+	target := filepath.Join(parent, "target")
+	pkg := schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Resource": {},
+			"test:index:Removed":  {},
+		},
+	}
+	require.NoError(t, splitschema.WritePackageSpec(target, &pkg))
+	require.NoError(t, os.MkdirAll(filepath.Join(target, ".git"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(target, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0644))
+	targetInfo, err := os.Stat(target)
+	require.NoError(t, err)
+	splitschema.SetRenameDir(t, func(oldpath, newpath string) error {
+		if oldpath == target {
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EBUSY}
+		}
+		return os.Rename(oldpath, newpath)
+	})
+
+	delete(pkg.Resources, "test:index:Removed")
+	pkg.Resources["test:index:Added"] = schema.ResourceSpec{}
+	require.NoError(t, splitschema.WritePackageSpec(target, &pkg, splitschema.WriteOptionPrune()))
+
+	info, err := os.Stat(target)
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(targetInfo, info), "the destination directory should be kept")
+	resources := filepath.Join(target, "index", "resources")
+	assert.FileExists(t, filepath.Join(resources, "added-"+shortHash("Added")+".json"))
+	assert.FileExists(t, filepath.Join(resources, "resource-"+shortHash("Resource")+".json"))
+	assert.NoFileExists(t, filepath.Join(resources, "removed-"+shortHash("Removed")+".json"))
+	assert.FileExists(t, filepath.Join(target, ".git", "HEAD"))
+	read, err := splitschema.ReadPackageSpec(target)
+	require.NoError(t, err)
+	assert.Len(t, read.Resources, 2)
+
+	entries, err := os.ReadDir(target)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), "staging", "staging directories should be cleaned up")
+	}
+	entries, err = os.ReadDir(parent)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "staging directories should be cleaned up")
+}
+
+func TestStagedWriteWithUnwritableParent(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("directory permissions don't apply to root")
+	}
+	parent := t.TempDir()
+	target := filepath.Join(parent, "target")
+	pkg := schema.PackageSpec{
+		Name:      "test",
+		Resources: map[string]schema.ResourceSpec{"test:index:Resource": {}},
+	}
+	require.NoError(t, splitschema.WritePackageSpec(target, &pkg))
+	require.NoError(t, os.Chmod(parent, 0555))
+	t.Cleanup(func() { _ = os.Chmod(parent, 0755) })
+
+	pkg.Resources["test:index:Added"] = schema.ResourceSpec{}
+	require.NoError(t, splitschema.WritePackageSpec(target, &pkg))
+
+	assert.FileExists(t, filepath.Join(target, "index", "resources", "added-"+shortHash("Added")+".json"))
+	entries, err := os.ReadDir(target)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), "staging", "staging directories should be cleaned up")
+	}
+}
+
+// writeSyntheticPackage writes a package with about as many resources, functions and types as azure-native, for
+// benchmarking when the provider schemas haven't been downloaded.
+func writeSyntheticPackage(b *testing.B) string {
+	pkg := &schema.PackageSpec{
+		Name:      "synthetic",
+		Resources: map[string]schema.ResourceSpec{},
+		Functions: map[string]schema.FunctionSpec{},
+		Types:     map[string]schema.ComplexTypeSpec{},
+	}
+	properties := map[string]schema.PropertySpec{}
+	for i := 0; i < 20; i++ {
+		properties[fmt.Sprintf("property%d", i)] = schema.PropertySpec{
+			TypeSpec:    schema.TypeSpec{Type: "string"},
+			Description: fmt.Sprintf("The value of property %d.", i),
+		}
+	}
+	for i := 0; i < 10000; i++ {
+		module := fmt.Sprintf("mod%d", i%200)
+		pkg.Resources[fmt.Sprintf("synthetic:%s:Resource%d", module, i)] = schema.ResourceSpec{
+			ObjectTypeSpec:  schema.ObjectTypeSpec{Description: fmt.Sprintf("Resource %d.", i), Properties: properties},
+			InputProperties: properties,
+		}
+		pkg.Functions[fmt.Sprintf("synthetic:%s:getResource%d", module, i)] = schema.FunctionSpec{
+			Description: fmt.Sprintf("Function %d.", i),
+			Outputs:     &schema.ObjectTypeSpec{Properties: properties},
+		}
+		for j := 0; j < 3; j++ {
+			pkg.Types[fmt.Sprintf("synthetic:%s:Type%dx%d", module, i, j)] = schema.ComplexTypeSpec{
+				ObjectTypeSpec: schema.ObjectTypeSpec{Type: "object", Properties: properties},
+			}
+		}
+	}
+	dir := b.TempDir()
+	if err := splitschema.WritePackageSpec(dir, pkg); err != nil {
+		b.Fatal(err)
+	}
+	return dir
+}
+
+// peakSampler polls the number of goroutines, the memory used by heap objects and the memory used by goroutine stacks,
+// recording the peaks while a benchmark runs.
+type peakSampler struct {
+	stop       chan struct{}
+	done       chan struct{}
+	goroutines int
+	heapBytes  uint64
+	stackBytes uint64
+}
+
+func startPeakSampler() *peakSampler {
+	s := &peakSampler{stop: make(chan struct{}), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		samples := []metrics.Sample{
+			{Name: "/memory/classes/heap/objects:bytes"},
+			{Name: "/memory/classes/heap/stacks:bytes"},
+		}
+		ticker := time.NewTicker(50 * time.Microsecond)
+		defer ticker.Stop()
+		for {
+			s.goroutines = max(s.goroutines, runtime.NumGoroutine())
+			metrics.Read(samples)
+			s.heapBytes = max(s.heapBytes, samples[0].Value.Uint64())
+			s.stackBytes = max(s.stackBytes, samples[1].Value.Uint64())
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+// report stops sampling and reports the peaks as benchmark metrics.
+func (s *peakSampler) report(b *testing.B) {
+	close(s.stop)
+	<-s.done
+	b.ReportMetric(float64(s.goroutines), "peak-goroutines")
+	b.ReportMetric(float64(s.heapBytes)/(1<<20), "peak-heap-MB")
+	b.ReportMetric(float64(s.stackBytes)/(1<<20), "peak-stack-MB")
+}
+
+// BenchmarkReadConcurrency reads whole packages at different concurrency limits, reporting the peak number of
+// goroutines and the peak memory in use alongside the time, e.g. `go test -run '^$' -bench ReadConcurrency -cpu 8`.
+func BenchmarkReadConcurrency(b *testing.B) {
+	limits := []struct {
+		name  string
+		limit int
+	}{
+		{"serial", 1},
+		{"default", 0},
+		// Effectively one goroutine per token, as before the worker pool was introduced.
+		{"unbounded", math.MaxInt},
+	}
+	run := func(b *testing.B, name, dir string) {
+		for _, l := range limits {
+			limit := l.limit
+			b.Run(name+"/"+l.name, func(b *testing.B) {
+				b.ReportAllocs()
+				runtime.GC()
+				sampler := startPeakSampler()
+				for i := 0; i < b.N; i++ {
+					pkg := splitschema.NewLocalPartialPackage(dir, splitschema.ReadOptionConcurrency(limit))
+					_, err := pkg.ReadPackageSpec()
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				sampler.report(b)
+			})
+		}
+	}
+
+	for _, provider := range []string{"aws", "azure-native"} {
+		dir := filepath.Join("testdata", provider)
+		if _, err := os.Stat(dir); err != nil {
+			b.Logf("skipping %s: run go generate to download the schema", provider)
+			continue
+		}
+		run(b, provider, dir)
+	}
+	run(b, "synthetic", writeSyntheticPackage(b))
+}
+
+func TestPackageReaderInterface(t *testing.T) {
+	var reader splitschema.PackageReader = splitschema.NewPartialPackage(awsEmbeddedSplit, "testdata/aws")
+	tokens, err := reader.GetResourceTokens()
+	require.NoError(t, err)
+	require.NotEmpty(t, tokens)
+	resource, err := reader.GetResource(tokens[0])
+	require.NoError(t, err)
+	assert.NotNil(t, resource)
+
+	var metaReader splitschema.PackageReaderWithMetadata[map[string]any, any, any] = splitschema.NewPartialPackageWithMetadata[map[string]any, any, any](awsEmbeddedSplit, "testdata/aws")
+	_, err = metaReader.ReadPackageSpec()
+	require.NoError(t, err)
+}
+
+func TestTypedErrors(t *testing.T) {
+	token := "test:index:Resource"
+	pkg := schema.PackageSpec{
+		Name:      "test",
+		Resources: map[string]schema.ResourceSpec{token: {}},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg))
+
+	partialPkg := splitschema.NewLocalPartialPackage(dir)
+	_, err := partialPkg.GetResource("test:index:Missing")
+	assert.ErrorIs(t, err, splitschema.ErrTokenNotFound)
+	var notFound *splitschema.TokenNotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Equal(t, "test:index:Missing", notFound.Token)
+	assert.Equal(t, "resources", notFound.Kind)
+
+	_, err = partialPkg.GetFunction("not-a-token")
+	assert.ErrorIs(t, err, splitschema.ErrTokenNotFound)
+
+	resourcePath := filepath.Join("index", "resources", "resource-fea1b483.json")
+	require.FileExists(t, filepath.Join(dir, resourcePath))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, resourcePath), []byte("{not json"), 0644))
+	_, err = splitschema.NewLocalPartialPackage(dir).GetResource(token)
+	var decodeErr *splitschema.SpecDecodeError
+	require.ErrorAs(t, err, &decodeErr)
+	assert.Equal(t, token, decodeErr.Token)
+	assert.Equal(t, "resources", decodeErr.Kind)
+	assert.Equal(t, resourcePath, decodeErr.Path)
+	var syntaxErr *json.SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+	assert.NotErrorIs(t, err, splitschema.ErrTokenNotFound)
+}
+
+func TestTokenValidatedAgainstIndex(t *testing.T) {
+	token := "test:index:Resource"
+	pkg := schema.PackageSpec{
+		Name:      "test",
+		Resources: map[string]schema.ResourceSpec{token: {}},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg))
+
+	fsys := &countingFS{FS: os.DirFS(dir)}
+	partialPkg := splitschema.NewPartialPackage(fsys, ".")
+	_, err := partialPkg.GetResource("test:index:Missing")
+	assert.ErrorIs(t, err, splitschema.ErrTokenNotFound)
+	// Once the index is loaded, unknown tokens are rejected without touching the filesystem.
+	opened := fsys.total.Load()
+	_, err = partialPkg.GetResource("test:other:Missing")
+	assert.ErrorIs(t, err, splitschema.ErrTokenNotFound)
+	assert.Equal(t, opened, fsys.total.Load())
+
+	// A token in the index without a spec file is corruption rather than a missing token.
+	resourcePath := filepath.Join("index", "resources", "resource-fea1b483.json")
+	require.NoError(t, os.Remove(filepath.Join(dir, resourcePath)))
+	_, err = partialPkg.GetResource(token)
+	assert.ErrorIs(t, err, splitschema.ErrCorrupt)
+	assert.NotErrorIs(t, err, splitschema.ErrTokenNotFound)
+	var missingErr *splitschema.SpecFileMissingError
+	require.ErrorAs(t, err, &missingErr)
+	assert.Equal(t, token, missingErr.Token)
+	assert.Equal(t, resourcePath, missingErr.Path)
+}
+
+// failingFS fails to open a file until fail is cleared.
+type failingFS struct {
+	fs.FS
+	name string
+	fail atomic.Bool
+}
+
+func (f *failingFS) Open(name string) (fs.File, error) {
+	if name == f.name && f.fail.Load() {
+		return nil, fs.ErrPermission
+	}
+	return f.FS.Open(name)
+}
+
+func TestManifestReadLazily(t *testing.T) {
+	pkg := schema.PackageSpec{Name: "test", Resources: map[string]schema.ResourceSpec{"test:index:Resource": {}}}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg, splitschema.WriteOptionFormat("yaml")))
+
+	counting := &countingFS{FS: os.DirFS(dir)}
+	partialPkg := splitschema.NewPartialPackage(counting, ".")
+	assert.Zero(t, counting.total.Load(), "constructing a package mustn't read any files")
+
+	// A failed manifest read is retried rather than breaking the package for good.
+	failing := &failingFS{FS: counting, name: "manifest.json"}
+	failing.fail.Store(true)
+	partialPkg = splitschema.NewPartialPackage(failing, ".")
+	_, err := partialPkg.GetResource("test:index:Resource")
+	assert.ErrorIs(t, err, fs.ErrPermission)
+	failing.fail.Store(false)
+	resource, err := partialPkg.GetResource("test:index:Resource")
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources["test:index:Resource"], *resource)
+}
+
+func TestMetadataOnlyTokens(t *testing.T) {
+	pkg := schema.PackageSpec{
+		Name:      "test",
+		Resources: map[string]schema.ResourceSpec{"test:index:Resource": {}},
+		Functions: map[string]schema.FunctionSpec{"test:index:getThing": {}},
+	}
+	// Metadata can be written for tokens which aren't in the package.
+	metadata := splitschema.PackageMetadata{
+		Resources: map[string]any{"test:index:Resource": "meta", "test:index:MetaOnly": "only meta"},
+		Functions: map[string]any{"test:index:getMetaOnly": "only meta"},
+		Types:     map[string]any{"test:index:MetaOnlyType": "only meta"},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpecWithMetadata(dir, &pkg, &metadata))
+
+	partialPkg := splitschema.NewLocalPartialPackageWithMetadata[string, string, string](dir)
+	readPkg, err := partialPkg.ReadPackageSpec()
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources, readPkg.Resources)
+	assert.Equal(t, pkg.Functions, readPkg.Functions)
+	assert.Empty(t, readPkg.Types)
+
+	_, err = partialPkg.GetResource("test:index:MetaOnly")
+	assert.ErrorIs(t, err, splitschema.ErrTokenNotFound)
+	assert.NotErrorIs(t, err, splitschema.ErrCorrupt)
+	meta, err := partialPkg.GetResourceMeta("test:index:MetaOnly")
+	require.NoError(t, err)
+	assert.Equal(t, "only meta", *meta)
+	functionMeta, err := partialPkg.GetFunctionMeta("test:index:getMetaOnly")
+	require.NoError(t, err)
+	assert.Equal(t, "only meta", *functionMeta)
+	typeMeta, err := partialPkg.GetTypeMeta("test:index:MetaOnlyType")
+	require.NoError(t, err)
+	assert.Equal(t, "only meta", *typeMeta)
+}
+
+func TestReadsIndexedPaths(t *testing.T) {
+	token := "test:index:Resource"
+	pkg := schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			token: {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Multi-line\ndescription"}},
+		},
+	}
+	metadata := splitschema.PackageMetadata{
+		Resources: map[string]any{token: "meta"},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpecWithMetadata(dir, &pkg, &metadata))
+
+	// Hand-move the files and update the index to point at the new location.
+	oldPath := filepath.Join(dir, "index", "resources", "resource-fea1b483")
+	newPath := filepath.Join(dir, "moved", "resource")
+	require.NoError(t, os.MkdirAll(filepath.Dir(newPath), 0755))
+	for _, ext := range []string{".json", ".md", ".meta.json"} {
+		require.NoError(t, os.Rename(oldPath+ext, newPath+ext))
+	}
+	index, err := json.Marshal(map[string]string{token: "moved/resource"})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "resources.json"), index, 0644))
+
+	partialPkg := splitschema.NewLocalPartialPackageWithMetadata[string, any, any](dir)
+	resource, err := partialPkg.GetResource(token)
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources[token], *resource)
+	meta, err := partialPkg.GetResourceMeta(token)
+	require.NoError(t, err)
+	assert.Equal(t, "meta", *meta)
+}
+
+func TestCollidingTokens(t *testing.T) {
+	pkg := schema.PackageSpec{
+		Name: "aws",
+		Resources: map[string]schema.ResourceSpec{
+			"aws:ec2/instance:Instance": {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "First\ninstance"}},
+			"aws:ec2/other:Instance":    {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Second\ninstance"}},
+			"aws:EC2/third:Instance":    {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Third instance"}},
+			"aws:ec2/vpc:Vpc":           {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Not colliding"}},
+		},
+	}
+	metadata := splitschema.PackageMetadata{
+		Resources: map[string]any{
+			"aws:ec2/instance:Instance": "first",
+			"aws:ec2/other:Instance":    "second",
+		},
+	}
+
+	var indexes []map[string]string
+	for i := 0; i < 2; i++ {
+		dir := t.TempDir()
+		require.NoError(t, splitschema.WritePackageSpecWithMetadata(dir, &pkg, &metadata))
+
+		readPkg := splitschema.NewLocalPartialPackageWithMetadata[string, any, any](dir)
+		resources, err := readPkg.GetResources()
+		require.NoError(t, err)
+		assert.Equal(t, pkg.Resources, resources)
+		for token, expected := range metadata.Resources {
+			meta, err := readPkg.GetResourceMeta(token)
+			require.NoError(t, err)
+			assert.Equal(t, expected, *meta)
+		}
+
+		indexBytes, err := os.ReadFile(filepath.Join(dir, "resources.json"))
+		require.NoError(t, err)
+		var index map[string]string
+		require.NoError(t, json.Unmarshal(indexBytes, &index))
+		indexes = append(indexes, index)
+	}
+
+	index := indexes[0]
+	assert.Equal(t, indexes[0], indexes[1], "paths should be deterministic")
+	assert.NotEqual(t, index["aws:ec2/instance:Instance"], index["aws:ec2/other:Instance"])
+	assert.Equal(t, "ec2/resources/vpc-"+shortHash("Vpc"), index["aws:ec2/vpc:Vpc"], "non-colliding tokens keep the default path")
+}
+
+func shortHash(s string) string {
+	return fmt.Sprintf("%08x", crc32.Checksum([]byte(s), crc32.MakeTable(crc32.Castagnoli)))
+}
+
+func TestNestedModules(t *testing.T) {
+	token := "azure-native:network/v20230201:VirtualNetwork"
+	pkg := schema.PackageSpec{
+		Name: "azure-native",
+		Resources: map[string]schema.ResourceSpec{
+			token:                                   {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Versioned\nresource"}},
+			"azure-native:network:VirtualNetwork":   {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Default version"}},
+			"azure-native:compute/v20230301:Disk":   {},
+			"azure-native:compute/v20230301:Images": {},
+		},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg, splitschema.WriteOptionNestedModules()))
+
+	assert.FileExists(t, filepath.Join(dir, "network", "v20230201", "resources", "virtualnetwork-"+shortHash("VirtualNetwork")+".json"))
+	assert.FileExists(t, filepath.Join(dir, "network", "resources", "virtualnetwork-"+shortHash("VirtualNetwork")+".json"))
+	manifest, err := splitschema.ReadManifest(os.DirFS(dir), ".")
+	require.NoError(t, err)
+	assert.Equal(t, "nested-modules", manifest.Layout)
+
+	readSpec, err := splitschema.ReadPackageSpec(dir)
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources, readSpec.Resources)
+
+	// Without a recorded path in the index, the reader falls back to the layout from the manifest.
+	index, err := json.Marshal(map[string]string{token: ""})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "resources.json"), index, 0644))
+	resource, err := splitschema.NewLocalPartialPackage(dir).GetResource(token)
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources[token], *resource)
+}
+
+func TestLayoutsRejectEscapingTokens(t *testing.T) {
+	hashed := []string{"x:..:Foo", "x:../../../escaped:Foo", "x:index:..", "x:index:a/../../b"}
+	tests := []struct {
+		layout   splitschema.PathLayout
+		rejected []string
+		// accepted maps tokens whose `.`, `..` or empty segments don't become path elements to their paths.
+		accepted map[string]string
+	}{
+		{
+			layout:   splitschema.HashedLayout,
+			rejected: hashed,
+			accepted: map[string]string{
+				"x::Res":            "resources/res-" + shortHash("Res"),
+				"x:index/:Res":      "index/resources/res-" + shortHash("Res"),
+				"x:index/../..:Res": "index/resources/res-" + shortHash("Res"),
+			},
+		},
+		{
+			layout:   splitschema.NestedModulesLayout,
+			rejected: append([]string{"x:index/../..:Foo"}, hashed...),
+			accepted: map[string]string{
+				"x::Res":       "resources/res-" + shortHash("Res"),
+				"x:index/:Res": "index/resources/res-" + shortHash("Res"),
+			},
+		},
+		{
+			layout:   splitschema.ModuleShardedLayout,
+			rejected: hashed,
+			accepted: map[string]string{
+				"x::Res": "resources/" + shortHash("Res")[:2] + "/res-" + shortHash("Res"),
+			},
+		},
+		{
+			layout: splitschema.FlatLayout,
+			// The whole token is escaped into one file name.
+			accepted: map[string]string{
+				"x:../../..:Foo": "resources/x%3A..%2F..%2F..%3AFoo",
+				"x:index:..":     "resources/x%3Aindex%3A..",
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, token := range tt.rejected {
+			_, err := tt.layout.Path(token, "resources")
+			assert.Error(t, err, "%s: %s", tt.layout.Name(), token)
+			_, err = tt.layout.DisambiguatedPath(token, "resources")
+			assert.Error(t, err, "%s: %s", tt.layout.Name(), token)
+		}
+		for token, expected := range tt.accepted {
+			p, err := tt.layout.Path(token, "resources")
+			require.NoError(t, err, "%s: %s", tt.layout.Name(), token)
+			assert.Equal(t, expected, p, "%s: %s", tt.layout.Name(), token)
+			_, err = tt.layout.DisambiguatedPath(token, "resources")
+			assert.NoError(t, err, "%s: %s", tt.layout.Name(), token)
+		}
+	}
+
+	root := t.TempDir()
+	pkg := schema.PackageSpec{
+		Name:      "x",
+		Resources: map[string]schema.ResourceSpec{"x:../../../escaped:Foo": {}},
+	}
+	err := splitschema.WritePackageSpec(filepath.Join(root, "a", "b", "out"), &pkg, splitschema.WriteOptionNestedModules())
+	assert.ErrorContains(t, err, "invalid token")
+	assert.NoDirExists(t, filepath.Join(root, "escaped"))
+
+	// Tokens with an empty module are written to the kind directories at the root.
+	pkg = schema.PackageSpec{
+		Name:      "x",
+		Resources: map[string]schema.ResourceSpec{"x::Res": {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Root"}}},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg))
+	assert.FileExists(t, filepath.Join(dir, "resources", "res-"+shortHash("Res")+".json"))
+	readSpec, err := splitschema.ReadPackageSpec(dir)
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources, readSpec.Resources)
+	require.NoError(t, splitschema.ValidateLocalPackage(dir))
+}
+
+type upperLayout struct{}
+
+func init() {
+	splitschema.RegisterPathLayout(upperLayout{})
+}
+
+func (upperLayout) Name() string { return "test-upper" }
+
+func (upperLayout) Path(token, kind string) (string, error) {
+	return kind + "/" + strings.ToUpper(strings.NewReplacer(":", "_", "/", "_").Replace(token)), nil
+}
+
+func (l upperLayout) DisambiguatedPath(token, kind string) (string, error) {
+	path, err := l.Path(token, kind)
+	return path + "-" + shortHash(token), err
+}
+
+func TestPathLayouts(t *testing.T) {
+	pkg := schema.PackageSpec{
+		Name: "aws",
+		Resources: map[string]schema.ResourceSpec{
+			"aws:ec2/instance:Instance": {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Multi-line\ndescription"}},
+			"aws:ec2/other:Instance":    {},
+			"aws:s3/bucket:Bucket":      {},
+			"aws:s3/bucket:bucket":      {},
+		},
+	}
+	tests := []struct {
+		layout   splitschema.PathLayout
+		expected string
+	}{
+		{splitschema.HashedLayout, "s3/resources/bucket-" + shortHash("Bucket")},
+		{splitschema.NestedModulesLayout, "s3/bucket/resources/bucket-" + shortHash("Bucket")},
+		{splitschema.FlatLayout, "resources/aws%3As3%2Fbucket%3ABucket-" + shortHash("aws:s3/bucket:Bucket")},
+		{splitschema.ModuleShardedLayout, "s3/resources/" + shortHash("Bucket")[:2] + "/bucket-" + shortHash("Bucket")},
+		{upperLayout{}, "resources/AWS_S3_BUCKET_BUCKET-" + shortHash("aws:s3/bucket:Bucket")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.layout.Name(), func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, splitschema.WritePackageSpec(dir, &pkg, splitschema.WriteOptionPathLayout(tt.layout), splitschema.WriteOptionPrune()))
+			manifest, err := splitschema.ReadManifest(os.DirFS(dir), ".")
+			require.NoError(t, err)
+			assert.Equal(t, tt.layout.Name(), manifest.Layout)
+
+			indexBytes, err := os.ReadFile(filepath.Join(dir, "resources.json"))
+			require.NoError(t, err)
+			var index map[string]string
+			require.NoError(t, json.Unmarshal(indexBytes, &index))
+			assert.Equal(t, tt.expected, index["aws:s3/bucket:Bucket"])
+			assert.FileExists(t, filepath.Join(dir, filepath.FromSlash(tt.expected)+".json"))
+
+			readSpec, err := splitschema.ReadPackageSpec(dir)
+			require.NoError(t, err)
+			assert.Equal(t, pkg.Resources, readSpec.Resources)
+
+			// Re-splitting with pruning keeps the files written by the layout.
+			require.NoError(t, splitschema.WritePackageSpec(dir, &pkg, splitschema.WriteOptionPathLayout(tt.layout), splitschema.WriteOptionPrune()))
+			assert.FileExists(t, filepath.Join(dir, filepath.FromSlash(tt.expected)+".json"))
+		})
+	}
+}
+
+// escapingLayout is a custom layout which returns paths outside the split directory.
+type escapingLayout struct {
+	path string
+}
+
+func (escapingLayout) Name() string { return "test-escaping" }
+
+func (l escapingLayout) Path(token, kind string) (string, error) {
+	return l.path, nil
+}
+
+func (l escapingLayout) DisambiguatedPath(token, kind string) (string, error) {
+	return l.path, nil
+}
+
+func TestWriterRejectsEscapingLayoutPaths(t *testing.T) {
+	pkg := schema.PackageSpec{
+		Name:      "test",
+		Resources: map[string]schema.ResourceSpec{"test:index:Resource": {}},
+	}
+	for _, path := range []string{"../escaped", "/tmp/escaped", "resources/../../escaped", "", "./resources/x", `..\escaped`} {
+		root := t.TempDir()
+		err := splitschema.WritePackageSpec(filepath.Join(root, "out"), &pkg, splitschema.WriteOptionPathLayout(escapingLayout{path}))
+		assert.ErrorContains(t, err, "invalid layout path", path)
+		assert.NoFileExists(t, filepath.Join(root, "escaped.json"))
+		assert.NoDirExists(t, filepath.Join(root, "out"))
+	}
+}
+
+func TestRegisterPathLayoutRejectsDuplicates(t *testing.T) {
+	assert.Panics(t, func() { splitschema.RegisterPathLayout(splitschema.HashedLayout) })
+	assert.Panics(t, func() { splitschema.RegisterPathLayout(upperLayout{}) })
+	assert.Panics(t, func() { splitschema.RegisterPathLayout(nil) })
+
+	layout, ok := splitschema.LookupPathLayout("hashed")
+	assert.True(t, ok)
+	assert.Equal(t, splitschema.HashedLayout, layout)
+}
+
+func TestDeterministicOutput(t *testing.T) {
+	pkg, err := readPackage(filepath.Join("testdata", "aws.json"))
+	require.NoError(t, err)
+	for tok, res := range pkg.Resources {
+		res.Description += "\r\nWindows line endings stay in the spec."
+		pkg.Resources[tok] = res
+		break
+	}
+
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			first := t.TempDir()
+			second := t.TempDir()
+			require.NoError(t, splitschema.WritePackageSpec(first, pkg, splitschema.WriteOptionFormat(format)))
+			require.NoError(t, splitschema.WritePackageSpec(second, pkg, splitschema.WriteOptionFormat(format)))
+
+			firstFiles := readTree(t, first)
+			assert.Equal(t, firstFiles, readTree(t, second))
+			for path, content := range firstFiles {
+				if strings.HasSuffix(path, ".md") {
+					assert.NotContains(t, content, "\r", "%s should use LF line endings", path)
+				}
+				assert.True(t, strings.HasSuffix(content, "\n"), "%s should end with a newline", path)
+				if !strings.HasSuffix(path, ".md") {
+					// Descriptions ending in a newline legitimately end in two.
+					assert.False(t, strings.HasSuffix(content, "\n\n"), "%s should end with a single newline", path)
+				}
+			}
+
+			outOfDate, err := splitschema.CheckPackageSpec(first, pkg, splitschema.WriteOptionFormat(format))
+			require.NoError(t, err)
+			assert.Empty(t, outOfDate)
+
+			readSpec, err := splitschema.ReadPackageSpec(first)
+			require.NoError(t, err)
+			assert.Equal(t, pkg.Resources, readSpec.Resources)
+		})
+	}
+}
+
+func TestMarkdownTrailingNewline(t *testing.T) {
+	token := "test:index:Resource"
+	pkg := schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			token: {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "First line\nends with a newline\n"}},
+		},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg))
+	mdPath := filepath.Join(dir, "index", "resources", "resource-"+shortHash("Resource")+".md")
+	md, err := os.ReadFile(mdPath)
+	require.NoError(t, err)
+	assert.Equal(t, "First line\nends with a newline\n\n", string(md))
+
+	readSpec, err := splitschema.ReadPackageSpec(dir)
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources, readSpec.Resources)
+
+	// Directories written before layout version 2 didn't add a newline, so it must be kept.
+	manifest := `{"layoutVersion": 1, "format": "json", "hashAlgorithm": "crc32c"}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "manifest.json"), []byte(manifest), 0644))
+	require.NoError(t, os.WriteFile(mdPath, []byte("Legacy\ndescription\n"), 0644))
+	resource, err := splitschema.NewLocalPartialPackage(dir).GetResource(token)
+	require.NoError(t, err)
+	assert.Equal(t, "Legacy\ndescription\n", resource.Description)
+}
+
+func TestPropertyDescriptions(t *testing.T) {
+	token := "test:index:Resource"
+	pkg := schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			token: {
+				ObjectTypeSpec: schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{
+						"arn":      {Description: "Single line"},
+						"userData": {Description: "First line\n\n```sh\necho hello\n```"},
+					},
+				},
+				InputProperties: map[string]schema.PropertySpec{
+					"user/Data": {Description: "Escaped\nname"},
+					"Clash":     {Description: "Upper\ncase"},
+					"clash":     {Description: "Lower\ncase"},
+				},
+			},
+		},
+		Functions: map[string]schema.FunctionSpec{
+			"test:index:getThing": {
+				Inputs: &schema.ObjectTypeSpec{Properties: map[string]schema.PropertySpec{"id": {Description: "Input\nid"}}},
+				ReturnType: &schema.ReturnTypeSpec{
+					ObjectTypeSpec: &schema.ObjectTypeSpec{Properties: map[string]schema.PropertySpec{"id": {Description: "Output\nid"}}},
+				},
+			},
+		},
+		Types: map[string]schema.ComplexTypeSpec{
+			"test:index:Type": {ObjectTypeSpec: schema.ObjectTypeSpec{
+				Type:       "object",
+				Properties: map[string]schema.PropertySpec{"value": {Description: "Type\nproperty"}},
+			}},
+		},
+	}
+	original, err := json.Marshal(pkg)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg, splitschema.WriteOptionPropertyDescriptions()))
+	manifest, err := splitschema.ReadManifest(os.DirFS(dir), ".")
+	require.NoError(t, err)
+	assert.Equal(t, 3, manifest.LayoutVersion)
+	assert.True(t, manifest.PropertyDescriptions)
+
+	// Writing mustn't modify the package being written.
+	written, err := json.Marshal(pkg)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(original), string(written))
+
+	propsDir := filepath.Join(dir, "index", "resources", "resource-"+shortHash("Resource")+".props")
+	userData, err := os.ReadFile(filepath.Join(propsDir, "properties", "userData.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "First line\n\n```sh\necho hello\n```\n", string(userData))
+	assert.FileExists(t, filepath.Join(propsDir, "inputProperties", "user%2FData.md"))
+	assert.NoFileExists(t, filepath.Join(propsDir, "properties", "arn.md"))
+	// Properties which only differ by case keep their descriptions in the spec.
+	assert.NoFileExists(t, filepath.Join(propsDir, "inputProperties", "clash.md"))
+	specBytes, err := os.ReadFile(filepath.Join(dir, "index", "resources", "resource-"+shortHash("Resource")+".json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(specBytes), "echo hello")
+	assert.Contains(t, string(specBytes), "Upper\\ncase")
+
+	readSpec, err := splitschema.ReadPackageSpec(dir)
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources, readSpec.Resources)
+	assert.Equal(t, pkg.Functions, readSpec.Functions)
+	assert.Equal(t, pkg.Types, readSpec.Types)
+
+	// Rewriting without the option prunes the property description files.
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg, splitschema.WriteOptionPrune()))
+	assert.NoDirExists(t, propsDir)
+	manifest, err = splitschema.ReadManifest(os.DirFS(dir), ".")
+	require.NoError(t, err)
+	assert.Equal(t, 2, manifest.LayoutVersion)
+	readSpec, err = splitschema.ReadPackageSpec(dir)
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources, readSpec.Resources)
+}
+
+func TestExamples(t *testing.T) {
+	description := "Provides an instance.\n\n" +
+		"{{% examples %}}\n## Example Usage\n\n" +
+		"{{% example %}}\n### Basic Usage\n\n" +
+		"```typescript\nimport * as aws from \"@pulumi/aws\";\n\nconst instance = new aws.ec2.Instance(\"web\");\n```\n" +
+		"```python\nimport pulumi_aws as aws\n\n# Create an instance\ninstance = aws.ec2.Instance(\"web\")\n```\n" +
+		"{{% /example %}}\n" +
+		"{{% example %}}\n### Basic Usage\n\n```typescript\nconst second = 2;\n```\n{{% /example %}}\n" +
+		"{{% example %}}\n```hcl\nresource {}\n```\n{{% /example %}}\n" +
+		"{{% /examples %}}\n\n```sh\n$ pulumi import aws:ec2/instance:Instance web i-12345678\n```\n"
+	pkg := schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Instance": {ObjectTypeSpec: schema.ObjectTypeSpec{Description: description}},
+			// Descriptions which already contain a marker are kept as they are.
+			"test:index:Marker": {ObjectTypeSpec: schema.ObjectTypeSpec{
+				Description: "{{% example %}}\n<!-- splitschema:example basic.ts -->\n```ts\ncode\n```\n{{% /example %}}",
+			}},
+		},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg, splitschema.WriteOptionExamples()))
+	manifest, err := splitschema.ReadManifest(os.DirFS(dir), ".")
+	require.NoError(t, err)
+	assert.Equal(t, 4, manifest.LayoutVersion)
+	assert.True(t, manifest.Examples)
+
+	specPath := filepath.Join(dir, "index", "resources", "instance-"+shortHash("Instance"))
+	examples := readTree(t, specPath+".examples")
+	assert.Equal(t, map[string]string{
+		"basic-usage.ts":   "import * as aws from \"@pulumi/aws\";\n\nconst instance = new aws.ec2.Instance(\"web\");\n",
+		"basic-usage.py":   "import pulumi_aws as aws\n\n# Create an instance\ninstance = aws.ec2.Instance(\"web\")\n",
+		"basic-usage-2.ts": "const second = 2;\n",
+		"example-3.hcl":    "resource {}\n",
+	}, examples)
+	markdown, err := os.ReadFile(specPath + ".md")
+	require.NoError(t, err)
+	assert.Contains(t, string(markdown), "```typescript\n<!-- splitschema:example basic-usage.ts -->\n```\n")
+	// Code blocks outside of examples stay in the description.
+	assert.Contains(t, string(markdown), "$ pulumi import")
+	assert.NoDirExists(t, filepath.Join(dir, "index", "resources", "marker-"+shortHash("Marker")+".examples"))
+
+	readSpec, err := splitschema.ReadPackageSpec(dir)
+	require.NoError(t, err)
+	assert.Equal(t, pkg.Resources, readSpec.Resources)
+
+	// Examples and property descriptions together need the newer of their versions.
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg, splitschema.WriteOptionExamples(), splitschema.WriteOptionPropertyDescriptions()))
+	manifest, err = splitschema.ReadManifest(os.DirFS(dir), ".")
+	require.NoError(t, err)
+	assert.Equal(t, 4, manifest.LayoutVersion)
+}
+
+func TestDescriptionRules(t *testing.T) {
+	pkg := schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Short":     {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Short"}},
+			"test:index:Long":      {ObjectTypeSpec: schema.ObjectTypeSpec{Description: strings.Repeat("Long ", 20)}},
+			"test:index:MultiLine": {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Multi\nline"}},
+			"test:index:Empty":     {},
+		},
+	}
+	tests := []struct {
+		name     string
+		rule     splitschema.DescriptionRule
+		markdown []string
+	}{
+		{"default", nil, []string{"MultiLine"}},
+		{"multiline", splitschema.MultiLineDescriptions, []string{"MultiLine"}},
+		{"always", splitschema.AllDescriptions, []string{"Long", "MultiLine", "Short"}},
+		{"never", splitschema.NoDescriptions, nil},
+		{"length", splitschema.DescriptionsLongerThan(50), []string{"Long"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []splitschema.WriteOption
+			if tt.rule != nil {
+				opts = append(opts, splitschema.WriteOptionDescriptions(tt.rule))
+			}
+			dir := t.TempDir()
+			require.NoError(t, splitschema.WritePackageSpec(dir, &pkg, opts...))
+
+			var markdown []string
+			for _, name := range []string{"Empty", "Long", "MultiLine", "Short"} {
+				mdPath := filepath.Join(dir, "index", "resources", strings.ToLower(name)+"-"+shortHash(name)+".md")
+				if _, err := os.Stat(mdPath); err == nil {
+					markdown = append(markdown, name)
+				}
+			}
+			assert.Equal(t, tt.markdown, markdown)
+
+			readSpec, err := splitschema.ReadPackageSpec(dir)
+			require.NoError(t, err)
+			assert.Equal(t, pkg.Resources, readSpec.Resources)
+		})
+	}
+}
+
+func TestCheckPackageSpec(t *testing.T) {
+	pkg := schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Changed": {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Before"}},
+			"test:index:Removed": {},
+		},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, &pkg))
+	// Files which weren't written by the layout aren't checked.
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "docs", "types"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docs", "types", "notes.md"), []byte("notes"), 0644))
+
+	pkg.Resources = map[string]schema.ResourceSpec{
+		"test:index:Changed": {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "After"}},
+		"test:index:Added":   {},
+	}
+	outOfDate, err := splitschema.CheckPackageSpec(dir, &pkg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"index/resources/added-" + shortHash("Added") + ".json",
+		"index/resources/changed-" + shortHash("Changed") + ".json",
+		"index/resources/removed-" + shortHash("Removed") + ".json",
+		"resources.json",
+	}, outOfDate)
+}
+
+// readTree reads all files in a directory keyed by their slash-separated relative path.
+func readTree(t *testing.T, root string) map[string]string {
+	files := map[string]string{}
+	require.NoError(t, filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		files[filepath.ToSlash(rel)] = string(content)
+		return err
+	}))
+	return files
+}
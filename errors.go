@@ -0,0 +1,99 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// ErrTokenNotFound is matched by errors.Is when a requested resource, function or type doesn't exist in the package.
+var ErrTokenNotFound = errors.New("token not found")
+
+// ErrCorrupt is matched by errors.Is when the split directory is internally inconsistent or contains files which can't
+// be decoded.
+var ErrCorrupt = errors.New("split schema is corrupt")
+
+// TokenNotFoundError is returned when a requested resource, function or type doesn't exist in the package.
+type TokenNotFoundError struct {
+	Token string
+	// Kind is one of "resources", "functions" or "types".
+	Kind string
+}
+
+func (e *TokenNotFoundError) Error() string {
+	return fmt.Sprintf("%s %q not found", kindName(e.Kind), e.Token)
+}
+
+func (e *TokenNotFoundError) Is(target error) bool {
+	return target == ErrTokenNotFound
+}
+
+// SpecDecodeError is returned when a file in the split directory can't be decoded.
+type SpecDecodeError struct {
+	// Token is the token being read, or empty when reading a file which isn't specific to a token such as core.json.
+	Token string
+	// Kind is one of "resources", "functions" or "types" when Token is set.
+	Kind string
+	// Path is the path of the file which couldn't be decoded, relative to the root of the split directory.
+	Path string
+	Err  error
+}
+
+func (e *SpecDecodeError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("decode %s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("decode %s %q from %s: %v", kindName(e.Kind), e.Token, e.Path, e.Err)
+}
+
+func (e *SpecDecodeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SpecDecodeError) Is(target error) bool {
+	return target == ErrCorrupt
+}
+
+// SpecFileMissingError is returned when a token is listed in an index file but neither its spec file nor its metadata
+// file exists. Tokens which only have metadata aren't found rather than corrupt.
+type SpecFileMissingError struct {
+	Token string
+	// Kind is one of "resources", "functions" or "types".
+	Kind string
+	// Path is the path of the missing file, relative to the root of the split directory.
+	Path string
+	Err  error
+}
+
+func (e *SpecFileMissingError) Error() string {
+	return fmt.Sprintf("%s %q is listed in %s but %s is missing", kindName(e.Kind), e.Token, e.Kind, e.Path)
+}
+
+func (e *SpecFileMissingError) Unwrap() error {
+	return e.Err
+}
+
+func (e *SpecFileMissingError) Is(target error) bool {
+	return target == ErrCorrupt
+}
+
+// tokenError adds token context to an error returned while reading the spec at path for a token which is listed in
+// the index, so a missing file means the directory is corrupt.
+func tokenError(err error, token, kind, path string) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return &SpecFileMissingError{Token: token, Kind: kind, Path: path, Err: err}
+	}
+	var decodeErr *SpecDecodeError
+	if errors.As(err, &decodeErr) {
+		return &SpecDecodeError{Token: token, Kind: kind, Path: decodeErr.Path, Err: decodeErr.Err}
+	}
+	return err
+}
+
+// kindName returns the singular name of a kind, e.g. "resource" for "resources".
+func kindName(kind string) string {
+	return strings.TrimSuffix(kind, "s")
+}
@@ -0,0 +1,159 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// examplesDirSuffix is appended to a spec's path to get the directory holding the code of its examples.
+const examplesDirSuffix = ".examples"
+
+const (
+	exampleMarkerPrefix = "<!-- splitschema:example "
+	exampleMarkerSuffix = " -->"
+)
+
+// exampleMarker matches a line in a markdown description which stands in for the code of an example in a file.
+var exampleMarker = regexp.MustCompile(`^` + exampleMarkerPrefix + `([a-z0-9][a-z0-9.-]*)` + exampleMarkerSuffix + `$`)
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// exampleExtensions maps the language of a code block to the file extension for its code.
+var exampleExtensions = map[string]string{
+	"typescript": "ts",
+	"javascript": "js",
+	"python":     "py",
+	"go":         "go",
+	"csharp":     "cs",
+	"java":       "java",
+	"yaml":       "yaml",
+}
+
+// extractExamples replaces the code of each code block within the `{{% example %}}` sections of a description with a
+// marker line, returning the new description and the code keyed by file name. Files are named after the heading of the
+// example and the language of the code block, e.g. `basic-usage.ts`.
+//
+// Descriptions which already contain a marker are returned unchanged so they can be read back exactly.
+func extractExamples(description string) (string, map[string]string) {
+	if strings.Contains(description, exampleMarkerPrefix) {
+		return description, nil
+	}
+
+	lines := strings.SplitAfter(description, "\n")
+	var b strings.Builder
+	files := map[string]string{}
+	inExample := false
+	exampleCount := 0
+	title := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSuffix(line, "\n")
+		switch {
+		case trimmed == "{{% example %}}":
+			inExample = true
+			exampleCount++
+			title = ""
+		case trimmed == "{{% /example %}}":
+			inExample = false
+		case inExample && title == "" && strings.HasPrefix(trimmed, "#"):
+			title = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+		case inExample && strings.HasPrefix(trimmed, "```") && len(trimmed) > 3:
+			end := closingFence(lines, i+1)
+			if end <= i+1 {
+				break
+			}
+			name := exampleFileName(files, title, exampleCount, trimmed[3:])
+			files[name] = strings.Join(lines[i+1:end], "")
+			b.WriteString(line)
+			b.WriteString(exampleMarkerPrefix + name + exampleMarkerSuffix + "\n")
+			// Continue from the closing fence.
+			i = end - 1
+			continue
+		}
+		b.WriteString(line)
+	}
+	if len(files) == 0 {
+		return description, nil
+	}
+	return b.String(), files
+}
+
+// closingFence returns the index of the first line from start which closes a code block, or -1 if there's none.
+func closingFence(lines []string, start int) int {
+	for i := start; i < len(lines); i++ {
+		if lines[i] == "```\n" || lines[i] == "```" {
+			return i
+		}
+	}
+	return -1
+}
+
+// exampleFileName returns an unused file name for the code of an example in the given language.
+func exampleFileName(files map[string]string, title string, exampleIndex int, language string) string {
+	base := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if base == "" {
+		base = fmt.Sprintf("example-%d", exampleIndex)
+	}
+	language = strings.ToLower(strings.TrimSpace(language))
+	if fields := strings.Fields(language); len(fields) > 0 {
+		language = fields[0]
+	}
+	ext, ok := exampleExtensions[language]
+	if !ok {
+		ext = strings.Trim(nonSlugChars.ReplaceAllString(language, "-"), "-")
+	}
+	if ext == "" {
+		ext = "txt"
+	}
+
+	name := base + "." + ext
+	for n := 2; ; n++ {
+		if _, ok := files[name]; !ok {
+			return name
+		}
+		name = fmt.Sprintf("%s-%d.%s", base, n, ext)
+	}
+}
+
+// restoreExamples replaces each marker line in a description with the code read from the named file.
+func restoreExamples(description string, readFile func(name string) ([]byte, error)) (string, error) {
+	lines := strings.SplitAfter(description, "\n")
+	var b strings.Builder
+	for _, line := range lines {
+		match := exampleMarker.FindStringSubmatch(strings.TrimSuffix(line, "\n"))
+		if match == nil {
+			b.WriteString(line)
+			continue
+		}
+		code, err := readFile(match[1])
+		if err != nil {
+			return "", err
+		}
+		b.Write(code)
+	}
+	return b.String(), nil
+}
+
+// readExamples restores the code of the examples in a description written alongside the spec at pathExExt.
+func (r *reader) readExamples(pathExExt, description string) (string, error) {
+	if !strings.Contains(description, exampleMarkerPrefix) {
+		return description, nil
+	}
+	dir := pathExExt + examplesDirSuffix
+	if _, err := fs.Stat(r.fs, filepath.Join(r.basePath, dir)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// The description contained a marker before it was written, so no examples were extracted.
+			return description, nil
+		}
+		return "", err
+	}
+	return restoreExamples(description, func(name string) ([]byte, error) {
+		return r.ReadFile(dir + "/" + name)
+	})
+}
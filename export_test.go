@@ -0,0 +1,15 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"testing"
+)
+
+// SetRenameDir replaces the function which renames an existing directory aside during a staged write until the test
+// ends.
+func SetRenameDir(t testing.TB, rename func(oldpath, newpath string) error) {
+	previous := renameDir
+	renameDir = rename
+	t.Cleanup(func() { renameDir = previous })
+}
@@ -3,29 +3,101 @@
 package splitschema
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"hash/crc32"
+	"io/fs"
+	"path"
 	"path/filepath"
 	"strings"
 
-	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	yaml "gopkg.in/yaml.v3"
 )
 
-func getPath(token string, kind string) (string, error) {
-	t, err := tokens.ParseTypeToken(token)
+// layoutFileSet is the set of files belonging to the split layout of a directory, as recorded by its index files.
+type layoutFileSet struct {
+	// files holds the spec, description and metadata files of each token.
+	files map[string]bool
+	// dirs holds the property description and example directories of each token, whose files all belong to the token.
+	dirs map[string]bool
+	// parents holds every directory containing files or directories of the layout, excluding the root.
+	parents map[string]bool
+}
+
+// indexedLayoutFiles returns the files belonging to the split layout of a directory: the manifest, core and index
+// files, along with the files of each token in the index files. Unreadable index files are treated as empty, so
+// files which can't be attributed to a token are never considered part of the layout.
+func indexedLayoutFiles(fsys fs.FS, basePath string) *layoutFileSet {
+	set := &layoutFileSet{files: map[string]bool{}, dirs: map[string]bool{}, parents: map[string]bool{}}
+	p := newPartialPackage(fsys, basePath)
+	r, err := p.getReader()
 	if err != nil {
-		return "", err
+		return set
+	}
+	for _, kind := range []string{"resources", "functions", "types"} {
+		var mappings *tokenMappings
+		var err error
+		switch kind {
+		case "resources":
+			mappings, err = p.getResourceTokenMappings()
+		case "functions":
+			mappings, err = p.getFunctionTokenMappings()
+		case "types":
+			mappings, err = p.getTypeTokenMappings()
+		}
+		if err != nil {
+			continue
+		}
+		for _, token := range mappings.list {
+			specPath, err := p.specPath(token, kind)
+			if err != nil {
+				continue
+			}
+			set.files[r.dataPath(specPath)] = true
+			set.files[specPath+".md"] = true
+			set.files[r.dataPath(specPath+".meta")] = true
+			set.dirs[specPath+propertiesDirSuffix] = true
+			set.dirs[specPath+examplesDirSuffix] = true
+			for dir := path.Dir(specPath); dir != "." && !set.parents[dir]; dir = path.Dir(dir) {
+				set.parents[dir] = true
+			}
+		}
 	}
+	return set
+}
+
+// containsWithin reports whether a slash-separated directory, relative to the root of the split directory, holds
+// any files belonging to the layout.
+func (s *layoutFileSet) containsWithin(relDir string) bool {
+	return s.parents[relDir] || s.dirs[relDir] || s.contains(relDir)
+}
 
-	modName := t.Module().Name().String()
-	typeName := t.Name().String()
+// contains reports whether a slash-separated path, relative to the root of the split directory, belongs to the layout.
+func (s *layoutFileSet) contains(relPath string) bool {
+	if isRootLayoutFile(relPath) || s.files[relPath] {
+		return true
+	}
+	for dir := path.Dir(relPath); dir != "."; dir = path.Dir(dir) {
+		if s.dirs[dir] {
+			return true
+		}
+	}
+	return false
+}
 
-	if parts := strings.Split(modName, "/"); len(parts) > 0 {
-		modName = parts[0]
+// isRootLayoutFile reports whether a path, relative to the root of a split directory, is the manifest, core or one of
+// the index files, in either format.
+func isRootLayoutFile(relPath string) bool {
+	if relPath == manifestFileName {
+		return true
+	}
+	ext := filepath.Ext(relPath)
+	switch strings.TrimSuffix(relPath, ext) {
+	case "core", "resources", "functions", "types":
+		return ext == ".json" || ext == ".yaml"
 	}
-	filename := makeFileName(typeName)
-	path := filepath.Join(modName, kind, filename)
-	return path, nil
+	return false
 }
 
 func makeFileName(s string) string {
@@ -37,3 +109,142 @@ func shortHash(s string) string {
 }
 
 var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// marshalYAML converts data to YAML via its JSON representation so that JSON-specific marshalling (e.g. schema.RawMessage)
+// is respected. Styles inherited from the JSON source are cleared so multi-line strings are rendered as literal blocks.
+func marshalYAML(data any, indent int) ([]byte, error) {
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(jsonBytes, &node); err != nil {
+		return nil, err
+	}
+	resetYAMLStyle(&node)
+
+	if indent < 2 {
+		indent = 2
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(indent)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+// unmarshalYAML reads YAML into data via its JSON representation, mirroring marshalYAML. The JSON is built from the
+// YAML nodes rather than decoded values, so the key order and number formatting of schema.RawMessage values such as
+// Language survive a round trip.
+func unmarshalYAML(yamlBytes []byte, data any) error {
+	var node yaml.Node
+	if err := yaml.Unmarshal(yamlBytes, &node); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := yamlNodeToJSON(&buf, &node); err != nil {
+		return err
+	}
+	return json.Unmarshal(buf.Bytes(), data)
+}
+
+// yamlNodeToJSON writes a YAML node as compact JSON, keeping the order of mapping keys and the text of numbers.
+func yamlNodeToJSON(buf *bytes.Buffer, node *yaml.Node) error {
+	switch node.Kind {
+	case 0:
+		// An empty document.
+		buf.WriteString("null")
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			buf.WriteString("null")
+			return nil
+		}
+		return yamlNodeToJSON(buf, node.Content[0])
+	case yaml.AliasNode:
+		return yamlNodeToJSON(buf, node.Alias)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, err := json.Marshal(node.Content[i].Value)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			if err := yamlNodeToJSON(buf, node.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, child := range node.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := yamlNodeToJSON(buf, child); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.ScalarNode:
+		return yamlScalarToJSON(buf, node)
+	default:
+		return fmt.Errorf("line %d: unsupported YAML node kind %d", node.Line, node.Kind)
+	}
+	return nil
+}
+
+func yamlScalarToJSON(buf *bytes.Buffer, node *yaml.Node) error {
+	var value any
+	switch node.ShortTag() {
+	case "!!null":
+		buf.WriteString("null")
+		return nil
+	case "!!int", "!!float":
+		// Numbers which are already valid JSON are written as they are, e.g. keeping the trailing zero of `2.50`.
+		if isJSONNumber(node.Value) {
+			buf.WriteString(node.Value)
+			return nil
+		}
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+	case "!!bool":
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+	default:
+		value = node.Value
+	}
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	buf.Write(bytes)
+	return nil
+}
+
+// isJSONNumber reports whether s is a number in JSON syntax.
+func isJSONNumber(s string) bool {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return false
+	}
+	var number json.Number
+	return json.Unmarshal([]byte(s), &number) == nil
+}
@@ -0,0 +1,268 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+)
+
+// PathLayout decides where the files for each resource, function and type are written within a split directory.
+//
+// The layout's name is recorded in the manifest. Readers use the paths recorded in the index files, so a custom layout
+// only needs to be registered with RegisterPathLayout for directories whose index files don't record paths.
+type PathLayout interface {
+	// Name identifies the layout in the manifest.
+	Name() string
+	// Path returns the path for a token's files relative to the root of the split directory, using forward slashes
+	// and excluding the file extension. Kind is one of "resources", "functions" or "types".
+	Path(token, kind string) (string, error)
+	// DisambiguatedPath returns an alternative path for a token whose Path collides with another token's, ignoring
+	// case. It must differ for every token which shares a Path.
+	DisambiguatedPath(token, kind string) (string, error)
+}
+
+var (
+	// HashedLayout is the default layout. Files are written to `{module}/{kind}/{name}-{hash}` where module is the
+	// first segment of the token's module and hash is a CRC32-Castagnoli hash of the type name.
+	HashedLayout PathLayout = hashedLayout{nested: false}
+	// NestedModulesLayout is like HashedLayout but uses the full module path as nested directories, e.g.
+	// `network/v20230201/resources/virtualnetwork-{hash}` for `azure-native:network/v20230201:VirtualNetwork`.
+	NestedModulesLayout PathLayout = hashedLayout{nested: true}
+	// FlatLayout writes every file to `{kind}/{token}`, escaping characters which aren't safe in file names.
+	FlatLayout PathLayout = flatLayout{}
+	// ModuleShardedLayout is like HashedLayout but shards each module's files into subdirectories named after the
+	// first two characters of the hash, e.g. `ec2/resources/53/instance-539d0ba2`, to keep directories small.
+	ModuleShardedLayout PathLayout = shardedLayout{}
+)
+
+const hashedLayoutName = "hashed"
+
+var pathLayouts = struct {
+	sync.RWMutex
+	byName map[string]PathLayout
+}{
+	byName: map[string]PathLayout{
+		HashedLayout.Name():        HashedLayout,
+		NestedModulesLayout.Name(): NestedModulesLayout,
+		FlatLayout.Name():          FlatLayout,
+		ModuleShardedLayout.Name(): ModuleShardedLayout,
+	},
+}
+
+// RegisterPathLayout makes a custom layout discoverable by readers through its name in the manifest. Like
+// database/sql.Register, it panics if the layout is nil, has no name, or a layout with the same name (including a
+// built-in layout) is already registered.
+func RegisterPathLayout(layout PathLayout) {
+	pathLayouts.Lock()
+	defer pathLayouts.Unlock()
+	if layout == nil {
+		panic("splitschema: RegisterPathLayout layout is nil")
+	}
+	name := layout.Name()
+	if name == "" {
+		panic("splitschema: RegisterPathLayout layout has no name")
+	}
+	if _, dup := pathLayouts.byName[name]; dup {
+		panic("splitschema: RegisterPathLayout called twice for layout " + name)
+	}
+	pathLayouts.byName[name] = layout
+}
+
+// LookupPathLayout returns the built-in or registered layout with the given name. An empty name refers to
+// HashedLayout, for directories written before layouts were recorded in the manifest.
+func LookupPathLayout(name string) (PathLayout, bool) {
+	if name == "" {
+		return HashedLayout, true
+	}
+	pathLayouts.RLock()
+	defer pathLayouts.RUnlock()
+	layout, ok := pathLayouts.byName[name]
+	return layout, ok
+}
+
+type hashedLayout struct {
+	nested bool
+}
+
+func (l hashedLayout) Name() string {
+	if l.nested {
+		return "nested-modules"
+	}
+	return hashedLayoutName
+}
+
+func (l hashedLayout) Path(token, kind string) (string, error) {
+	modName, typeName, err := splitToken(token, l.nested)
+	if err != nil {
+		return "", err
+	}
+	return joinLayoutPath(modName, kind, makeFileName(typeName))
+}
+
+// DisambiguatedPath hashes the whole token rather than just the type name.
+func (l hashedLayout) DisambiguatedPath(token, kind string) (string, error) {
+	modName, typeName, err := splitToken(token, l.nested)
+	if err != nil {
+		return "", err
+	}
+	return joinLayoutPath(modName, kind, strings.ToLower(typeName)+"-"+shortHash(token))
+}
+
+type flatLayout struct{}
+
+func (flatLayout) Name() string {
+	return "flat"
+}
+
+// Path escapes the whole token into a single file name, so no part of it can become a separate path element.
+func (flatLayout) Path(token, kind string) (string, error) {
+	if _, err := tokens.ParseTypeToken(token); err != nil {
+		return "", err
+	}
+	return joinLayoutPath(kind, escapeFileName(token))
+}
+
+// DisambiguatedPath appends a hash of the token, for tokens which only differ by case.
+func (l flatLayout) DisambiguatedPath(token, kind string) (string, error) {
+	p, err := l.Path(token, kind)
+	if err != nil {
+		return "", err
+	}
+	return p + "-" + shortHash(token), nil
+}
+
+type shardedLayout struct{}
+
+func (shardedLayout) Name() string {
+	return "module-sharded"
+}
+
+func (shardedLayout) Path(token, kind string) (string, error) {
+	modName, typeName, err := splitToken(token, false)
+	if err != nil {
+		return "", err
+	}
+	hash := shortHash(typeName)
+	return joinLayoutPath(modName, kind, hash[:2], strings.ToLower(typeName)+"-"+hash)
+}
+
+func (shardedLayout) DisambiguatedPath(token, kind string) (string, error) {
+	modName, typeName, err := splitToken(token, false)
+	if err != nil {
+		return "", err
+	}
+	hash := shortHash(token)
+	return joinLayoutPath(modName, kind, hash[:2], strings.ToLower(typeName)+"-"+hash)
+}
+
+// layoutFilePatterns match the paths, excluding extensions, which the built-in layouts write each token's files to. They
+// recognise files left behind by tokens which are no longer in any index, even when no indexed token shares their
+// directory.
+var layoutFilePatterns = map[string]*regexp.Regexp{
+	hashedLayoutName:           regexp.MustCompile(`^([^/]+/)?(resources|functions|types)/[^/]+-[0-9a-f]{8}$`),
+	NestedModulesLayout.Name(): regexp.MustCompile(`^([^/]+/)*(resources|functions|types)/[^/]+-[0-9a-f]{8}$`),
+	ModuleShardedLayout.Name(): regexp.MustCompile(`^([^/]+/)?(resources|functions|types)/[0-9a-f]{2}/[^/]+-[0-9a-f]{8}$`),
+	FlatLayout.Name():          regexp.MustCompile(`^(resources|functions|types)/[^/]*%3A[^/]*%3A[^/]*$`),
+}
+
+// isLayoutShapedFile reports whether a slash-separated path, relative to the root of the split directory, has the
+// shape of a spec, description, metadata, property description or example file written by the named built-in layout.
+// It's always false for custom layouts, whose paths can't be recognised.
+func isLayoutShapedFile(layoutName, relPath string) bool {
+	if layoutName == "" {
+		layoutName = hashedLayoutName
+	}
+	pattern, ok := layoutFilePatterns[layoutName]
+	if !ok {
+		return false
+	}
+	segments := strings.Split(relPath, "/")
+	for i, segment := range segments[:len(segments)-1] {
+		for _, suffix := range []string{propertiesDirSuffix, examplesDirSuffix} {
+			if base, ok := strings.CutSuffix(segment, suffix); ok {
+				return pattern.MatchString(path.Join(path.Join(segments[:i]...), base))
+			}
+		}
+	}
+	for _, ext := range []string{".meta.json", ".meta.yaml", ".json", ".yaml", ".md"} {
+		if base, ok := strings.CutSuffix(relPath, ext); ok {
+			return pattern.MatchString(base)
+		}
+	}
+	return false
+}
+
+// escapeFileName percent-encodes characters which aren't safe in file names on common filesystems.
+func escapeFileName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r < 0x20, strings.ContainsRune(`%/\:*?"<>|`, r):
+			fmt.Fprintf(&b, "%%%02X", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// splitToken returns the module directory and type name for a token. When nested is set, the full module path is
+// returned, otherwise only its first segment.
+func splitToken(token string, nested bool) (modName, typeName string, err error) {
+	t, err := tokens.ParseTypeToken(token)
+	if err != nil {
+		return "", "", err
+	}
+
+	modName = t.Module().Name().String()
+	typeName = t.Name().String()
+
+	if !nested {
+		// Handle module names which include type name after "/"
+		modName, _, _ = strings.Cut(modName, "/")
+	}
+	if err := checkPathSegments(token, modName, typeName); err != nil {
+		return "", "", err
+	}
+	return modName, typeName, nil
+}
+
+// checkPathSegments rejects `.` and `..` segments in the parts of a token which a layout turns into path elements, as
+// they could place the token's files outside its directory or the split directory. Empty segments are dropped when
+// the path is joined, so an empty module writes to the kind directory at the root as it always has.
+func checkPathSegments(token string, parts ...string) error {
+	for _, part := range parts {
+		for _, segment := range strings.Split(part, "/") {
+			if segment == "." || segment == ".." {
+				return fmt.Errorf("invalid token %q: %q must not contain \".\" or \"..\" segments", token, part)
+			}
+		}
+	}
+	return nil
+}
+
+// joinLayoutPath joins the elements of a layout path, rejecting the result unless it's a valid path within the split
+// directory.
+func joinLayoutPath(elem ...string) (string, error) {
+	p := path.Join(elem...)
+	if err := checkLayoutPath(p); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// checkLayoutPath returns an error unless p is a valid slash-separated path within the split directory, i.e. it's
+// relative and has no empty, `.` or `..` segments.
+func checkLayoutPath(p string) error {
+	if !fs.ValidPath(p) || p == "." || strings.Contains(p, `\`) {
+		return fmt.Errorf("invalid layout path %q: must be a relative path within the split directory", p)
+	}
+	return nil
+}
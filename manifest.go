@@ -0,0 +1,139 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+)
+
+// LayoutVersion is the newest version of the split directory layout supported by this version of the library.
+// Readers refuse to read directories written with a newer layout version, so directories are written with the lowest
+// version which supports the options used (see writeLayoutVersion).
+//
+// Version 2 adds a trailing newline to markdown description files which readers remove.
+// Version 3 adds property description files, recorded by Manifest.PropertyDescriptions.
+// Version 4 adds example code files, recorded by Manifest.Examples.
+const LayoutVersion = 4
+
+// legacyLayoutVersion is assumed for directories written without a manifest.
+const legacyLayoutVersion = 1
+
+const manifestFileName = "manifest.json"
+
+const modulePath = "github.com/pulumi/splitschema"
+
+// Manifest describes how a split directory was written. It's always written as JSON, regardless of the format of
+// the rest of the files, so it can be read before the format is known.
+type Manifest struct {
+	LayoutVersion int    `json:"layoutVersion"`
+	Format        string `json:"format"`
+	HashAlgorithm string `json:"hashAlgorithm"`
+	// Layout is the name of the PathLayout used to write the directory. Empty for directories written before layouts
+	// were recorded, which use HashedLayout.
+	Layout  string `json:"layout,omitempty"`
+	Compact bool   `json:"compact,omitempty"`
+	// PropertyDescriptions is set when multi-line property descriptions were written to markdown files in a `.props`
+	// directory next to each spec.
+	PropertyDescriptions bool `json:"propertyDescriptions,omitempty"`
+	// Examples is set when the code of examples in markdown descriptions was written to files in a `.examples`
+	// directory next to each spec.
+	Examples bool `json:"examples,omitempty"`
+	// ToolVersion is the release of splitschema which wrote the directory. It's left empty for development builds,
+	// whose pseudo-versions change with every commit, so rebuilding the tool doesn't change the manifest.
+	ToolVersion string `json:"toolVersion,omitempty"`
+}
+
+// ReadManifest reads the manifest from a split directory. If the directory was written before manifests were
+// introduced, a manifest describing the legacy layout is returned.
+func ReadManifest(fsys fs.FS, basePath string) (*Manifest, error) {
+	bytes, err := fs.ReadFile(fsys, filepath.Join(basePath, manifestFileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Manifest{
+				LayoutVersion: legacyLayoutVersion,
+				Format:        detectFormat(fsys, basePath),
+				HashAlgorithm: "crc32c",
+				Layout:        hashedLayoutName,
+			}, nil
+		}
+		return nil, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(bytes, &manifest); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", manifestFileName, err)
+	}
+	if err := manifest.validate(); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// writeLayoutVersion returns the lowest layout version which supports the options a directory is written with, so
+// readers which predate an optional feature can still read directories which don't use it.
+func writeLayoutVersion(options *WriteOptions) int {
+	switch {
+	case options.Examples:
+		return 4
+	case options.PropertyDescriptions:
+		return 3
+	default:
+		return 2
+	}
+}
+
+func (m *Manifest) validate() error {
+	if m.LayoutVersion > LayoutVersion {
+		return fmt.Errorf("split schema layout version %d is newer than the supported version %d (written by splitschema %s); upgrade splitschema to read it",
+			m.LayoutVersion, LayoutVersion, m.ToolVersion)
+	}
+	if m.LayoutVersion < 1 {
+		return fmt.Errorf("invalid split schema layout version %d", m.LayoutVersion)
+	}
+	if m.Format != "json" && m.Format != "yaml" {
+		return fmt.Errorf("unsupported format: %s", m.Format)
+	}
+	if m.HashAlgorithm != "crc32c" {
+		return fmt.Errorf("unsupported hash algorithm: %s", m.HashAlgorithm)
+	}
+	return nil
+}
+
+// toolVersion returns the release version of this module as recorded in the build info of the running binary, or
+// empty if the binary wasn't built from a release.
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == modulePath {
+		return releaseVersion(info.Main.Version)
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return releaseVersion(dep.Version)
+		}
+	}
+	return ""
+}
+
+var (
+	releaseVersionPattern = regexp.MustCompile(`^v\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+	// pseudoVersionPattern matches the timestamp and commit suffix of a pseudo-version, e.g.
+	// `v0.0.0-20240102150405-abcdef123456`.
+	pseudoVersionPattern = regexp.MustCompile(`\d{14}-[0-9a-f]{12}$`)
+)
+
+// releaseVersion returns version if it's a tagged release, or empty for development builds such as `(devel)`,
+// pseudo-versions and builds with uncommitted changes.
+func releaseVersion(version string) string {
+	if !releaseVersionPattern.MatchString(version) || pseudoVersionPattern.MatchString(version) {
+		return ""
+	}
+	return version
+}
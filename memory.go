@@ -0,0 +1,147 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+)
+
+// OpenPackageReader returns a PackageReader for either a split directory or a single JSON schema file.
+func OpenPackageReader(path string) (PackageReader, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return NewLocalPartialPackage(path), nil
+	}
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pkg schema.PackageSpec
+	if err := json.Unmarshal(bytes, &pkg); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return NewPackageReader(&pkg), nil
+}
+
+// NewPackageReader returns a PackageReader for a package which is already in memory, such as a schema read from a
+// single JSON file, so it can be used wherever a split directory can. The package must not be modified while the
+// reader is in use.
+func NewPackageReader(pkg *schema.PackageSpec) PackageReader {
+	return &memoryPackage{pkg: pkg}
+}
+
+type memoryPackage struct {
+	pkg *schema.PackageSpec
+}
+
+func (m *memoryPackage) ReadPackageSpec() (*schema.PackageSpec, error) {
+	return m.ReadPackageSpecContext(context.Background())
+}
+
+func (m *memoryPackage) ReadPackageSpecContext(ctx context.Context) (*schema.PackageSpec, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pkg := *m.pkg
+	pkg.Resources = maps.Clone(m.pkg.Resources)
+	pkg.Functions = maps.Clone(m.pkg.Functions)
+	pkg.Types = maps.Clone(m.pkg.Types)
+	return &pkg, nil
+}
+
+func (m *memoryPackage) GetResource(token string) (*schema.ResourceSpec, error) {
+	return m.GetResourceContext(context.Background(), token)
+}
+
+func (m *memoryPackage) GetResourceContext(ctx context.Context, token string) (*schema.ResourceSpec, error) {
+	return getMemorySpec(ctx, m.pkg.Resources, token, "resources")
+}
+
+func (m *memoryPackage) GetResources() (map[string]schema.ResourceSpec, error) {
+	return m.GetResourcesContext(context.Background())
+}
+
+func (m *memoryPackage) GetResourcesContext(ctx context.Context) (map[string]schema.ResourceSpec, error) {
+	return getMemorySpecs(ctx, m.pkg.Resources)
+}
+
+func (m *memoryPackage) GetResourceTokens() ([]string, error) {
+	return sortedKeys(m.pkg.Resources), nil
+}
+
+func (m *memoryPackage) GetFunction(token string) (*schema.FunctionSpec, error) {
+	return m.GetFunctionContext(context.Background(), token)
+}
+
+func (m *memoryPackage) GetFunctionContext(ctx context.Context, token string) (*schema.FunctionSpec, error) {
+	return getMemorySpec(ctx, m.pkg.Functions, token, "functions")
+}
+
+func (m *memoryPackage) GetFunctions() (map[string]schema.FunctionSpec, error) {
+	return m.GetFunctionsContext(context.Background())
+}
+
+func (m *memoryPackage) GetFunctionsContext(ctx context.Context) (map[string]schema.FunctionSpec, error) {
+	return getMemorySpecs(ctx, m.pkg.Functions)
+}
+
+func (m *memoryPackage) GetFunctionTokens() ([]string, error) {
+	return sortedKeys(m.pkg.Functions), nil
+}
+
+func (m *memoryPackage) GetType(token string) (*schema.ComplexTypeSpec, error) {
+	return m.GetTypeContext(context.Background(), token)
+}
+
+func (m *memoryPackage) GetTypeContext(ctx context.Context, token string) (*schema.ComplexTypeSpec, error) {
+	return getMemorySpec(ctx, m.pkg.Types, token, "types")
+}
+
+func (m *memoryPackage) GetTypes() (map[string]schema.ComplexTypeSpec, error) {
+	return m.GetTypesContext(context.Background())
+}
+
+func (m *memoryPackage) GetTypesContext(ctx context.Context) (map[string]schema.ComplexTypeSpec, error) {
+	return getMemorySpecs(ctx, m.pkg.Types)
+}
+
+func (m *memoryPackage) GetTypeTokens() ([]string, error) {
+	return sortedKeys(m.pkg.Types), nil
+}
+
+func getMemorySpec[T any](ctx context.Context, specs map[string]T, token, kind string) (*T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	spec, ok := specs[token]
+	if !ok {
+		return nil, &TokenNotFoundError{Token: token, Kind: kind}
+	}
+	return &spec, nil
+}
+
+func getMemorySpecs[T any](ctx context.Context, specs map[string]T) (map[string]T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return maps.Clone(specs), nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,61 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"context"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+)
+
+// PackageReader provides lazy access to the parts of a package schema. It's implemented by the packages returned
+// from NewPartialPackage and NewLocalPartialPackage, and can be implemented by fakes or alternate backends.
+//
+// Implementations must be safe for concurrent use.
+type PackageReader interface {
+	// ReadPackageSpec reads the whole package. The returned spec and its Resources, Functions and Types maps are new,
+	// but nested values may be shared with the implementation and must not be modified.
+	ReadPackageSpec() (*schema.PackageSpec, error)
+	ReadPackageSpecContext(ctx context.Context) (*schema.PackageSpec, error)
+
+	GetResource(token string) (*schema.ResourceSpec, error)
+	GetResourceContext(ctx context.Context, token string) (*schema.ResourceSpec, error)
+	GetResources() (map[string]schema.ResourceSpec, error)
+	GetResourcesContext(ctx context.Context) (map[string]schema.ResourceSpec, error)
+	// GetResourceTokens returns the resource tokens for the package sorted alphabetically. This includes tokens which
+	// only have metadata, for which GetResource returns a *TokenNotFoundError and which GetResources leaves out.
+	GetResourceTokens() ([]string, error)
+
+	GetFunction(token string) (*schema.FunctionSpec, error)
+	GetFunctionContext(ctx context.Context, token string) (*schema.FunctionSpec, error)
+	GetFunctions() (map[string]schema.FunctionSpec, error)
+	GetFunctionsContext(ctx context.Context) (map[string]schema.FunctionSpec, error)
+	// GetFunctionTokens returns the function tokens for the package sorted alphabetically, including tokens which only have
+	// metadata.
+	GetFunctionTokens() ([]string, error)
+
+	GetType(token string) (*schema.ComplexTypeSpec, error)
+	GetTypeContext(ctx context.Context, token string) (*schema.ComplexTypeSpec, error)
+	GetTypes() (map[string]schema.ComplexTypeSpec, error)
+	GetTypesContext(ctx context.Context) (map[string]schema.ComplexTypeSpec, error)
+	// GetTypeTokens returns the type tokens for the package sorted alphabetically, including tokens which only have
+	// metadata.
+	GetTypeTokens() ([]string, error)
+}
+
+// PackageReaderWithMetadata is a PackageReader which also provides typed, provider-specific metadata for each
+// resource, function and type. It's implemented by the packages returned from NewPartialPackageWithMetadata and
+// NewLocalPartialPackageWithMetadata.
+type PackageReaderWithMetadata[ResourceMeta, FunctionMeta, TypeMeta any] interface {
+	PackageReader
+
+	GetResourceMeta(token string) (*ResourceMeta, error)
+	GetFunctionMeta(token string) (*FunctionMeta, error)
+	GetTypeMeta(token string) (*TypeMeta, error)
+}
+
+var (
+	_ PackageReader                            = (*partialPackage)(nil)
+	_ PackageReader                            = (*memoryPackage)(nil)
+	_ PackageReaderWithMetadata[any, any, any] = (*partialPackageWithMetadata[any, any, any])(nil)
+)
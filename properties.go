@@ -0,0 +1,174 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"errors"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+)
+
+// propertiesDirSuffix is appended to a spec's path to get the directory holding its property descriptions.
+const propertiesDirSuffix = ".props"
+
+// propertyDescriptions holds the markdown descriptions of properties moved out of a spec, keyed by section and then by
+// property name. Sections are named after the JSON field holding the properties, e.g. "inputProperties".
+type propertyDescriptions map[string]map[string]string
+
+// extract moves the descriptions of props for which isMarkdown returns true into d, returning a copy of props without
+// them. Properties whose file names would only differ by case keep their descriptions so they can be written to
+// case-insensitive filesystems.
+func (d propertyDescriptions) extract(section string, props map[string]schema.PropertySpec, isMarkdown func(string) bool) map[string]schema.PropertySpec {
+	fileNames := map[string]int{}
+	for name, prop := range props {
+		if isMarkdown(prop.Description) {
+			fileNames[strings.ToLower(escapeFileName(name))]++
+		}
+	}
+	if len(fileNames) == 0 {
+		return props
+	}
+
+	propsCopy := make(map[string]schema.PropertySpec, len(props))
+	for name, prop := range props {
+		if isMarkdown(prop.Description) && fileNames[strings.ToLower(escapeFileName(name))] == 1 {
+			if d[section] == nil {
+				d[section] = map[string]string{}
+			}
+			d[section][name] = prop.Description
+			prop.Description = ""
+		}
+		propsCopy[name] = prop
+	}
+	return propsCopy
+}
+
+func (d propertyDescriptions) extractObject(section string, spec *schema.ObjectTypeSpec, isMarkdown func(string) bool) *schema.ObjectTypeSpec {
+	if spec == nil {
+		return nil
+	}
+	specCopy := *spec
+	specCopy.Properties = d.extract(section, spec.Properties, isMarkdown)
+	return &specCopy
+}
+
+func (d propertyDescriptions) extractResource(spec schema.ResourceSpec, isMarkdown func(string) bool) schema.ResourceSpec {
+	spec.Properties = d.extract("properties", spec.Properties, isMarkdown)
+	spec.InputProperties = d.extract("inputProperties", spec.InputProperties, isMarkdown)
+	spec.StateInputs = d.extractObject("stateInputs", spec.StateInputs, isMarkdown)
+	return spec
+}
+
+func (d propertyDescriptions) extractFunction(spec schema.FunctionSpec, isMarkdown func(string) bool) schema.FunctionSpec {
+	spec.Inputs = d.extractObject("inputs", spec.Inputs, isMarkdown)
+	spec.Outputs = d.extractObject("outputs", spec.Outputs, isMarkdown)
+	if spec.ReturnType != nil && spec.ReturnType.ObjectTypeSpec != nil {
+		returnType := *spec.ReturnType
+		// The return type is written to the same field as the outputs.
+		returnType.ObjectTypeSpec = d.extractObject("outputs", returnType.ObjectTypeSpec, isMarkdown)
+		spec.ReturnType = &returnType
+	}
+	return spec
+}
+
+func (d propertyDescriptions) extractType(spec schema.ComplexTypeSpec, isMarkdown func(string) bool) schema.ComplexTypeSpec {
+	spec.Properties = d.extract("properties", spec.Properties, isMarkdown)
+	return spec
+}
+
+// restore sets the descriptions of props from d. Descriptions of properties which don't exist are ignored.
+func (d propertyDescriptions) restore(section string, props map[string]schema.PropertySpec) {
+	for name, description := range d[section] {
+		if prop, ok := props[name]; ok {
+			prop.Description = description
+			props[name] = prop
+		}
+	}
+}
+
+func (d propertyDescriptions) restoreObject(section string, spec *schema.ObjectTypeSpec) {
+	if spec != nil {
+		d.restore(section, spec.Properties)
+	}
+}
+
+func (d propertyDescriptions) restoreResource(spec *schema.ResourceSpec) {
+	d.restore("properties", spec.Properties)
+	d.restore("inputProperties", spec.InputProperties)
+	d.restoreObject("stateInputs", spec.StateInputs)
+}
+
+func (d propertyDescriptions) restoreFunction(spec *schema.FunctionSpec) {
+	d.restoreObject("inputs", spec.Inputs)
+	d.restoreObject("outputs", spec.Outputs)
+	if spec.ReturnType != nil {
+		d.restoreObject("outputs", spec.ReturnType.ObjectTypeSpec)
+	}
+}
+
+func (d propertyDescriptions) restoreType(spec *schema.ComplexTypeSpec) {
+	d.restore("properties", spec.Properties)
+}
+
+// writePropertyDescriptions writes each description to `{path}.props/{section}/{property}.md`.
+func (w *writer) writePropertyDescriptions(pathExExt string, descriptions propertyDescriptions) error {
+	for section, props := range descriptions {
+		for name, description := range props {
+			filePath := path.Join(pathExExt+propertiesDirSuffix, section, escapeFileName(name)+".md")
+			if err := w.WriteFile(filePath, []byte(description+"\n")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readPropertyDescriptions reads the property descriptions written alongside a spec, if the directory was written
+// with property descriptions.
+func (r *reader) readPropertyDescriptions(pathExExt string) (propertyDescriptions, error) {
+	if !r.propertyDescriptions {
+		return nil, nil
+	}
+	dir := pathExExt + propertiesDirSuffix
+	sections, err := fs.ReadDir(r.fs, filepath.Join(r.basePath, dir))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	descriptions := propertyDescriptions{}
+	for _, section := range sections {
+		if !section.IsDir() {
+			continue
+		}
+		files, err := fs.ReadDir(r.fs, filepath.Join(r.basePath, dir, section.Name()))
+		if err != nil {
+			return nil, err
+		}
+		props := map[string]string{}
+		for _, file := range files {
+			fileName, ok := strings.CutSuffix(file.Name(), ".md")
+			if file.IsDir() || !ok {
+				continue
+			}
+			filePath := path.Join(dir, section.Name(), file.Name())
+			name, err := url.PathUnescape(fileName)
+			if err != nil {
+				return nil, &SpecDecodeError{Path: filePath, Err: err}
+			}
+			bytes, err := r.ReadFile(filePath)
+			if err != nil {
+				return nil, err
+			}
+			props[name] = strings.TrimSuffix(string(bytes), "\n")
+		}
+		descriptions[section.Name()] = props
+	}
+	return descriptions, nil
+}
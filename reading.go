@@ -3,42 +3,88 @@
 package splitschema
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
+	"strings"
 	"sync"
 	"sync/atomic"
 
 	ccmap "github.com/orcaman/concurrent-map/v2"
 	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
-	yaml "gopkg.in/yaml.v3"
 )
 
-func NewLocalPartialPackage(basePath string) partialPackage {
-	return NewPartialPackage(os.DirFS(basePath), ".")
+func NewLocalPartialPackage(basePath string, opts ...ReadOption) *partialPackage {
+	return NewPartialPackage(os.DirFS(basePath), ".", opts...)
 }
 
-func NewPartialPackage(fs fs.FS, basePath string) partialPackage {
+func NewPartialPackage(fs fs.FS, basePath string, opts ...ReadOption) *partialPackage {
+	pkg := newPartialPackage(fs, basePath, opts...)
+	return &pkg
+}
+
+func newPartialPackage(fs fs.FS, basePath string, opts ...ReadOption) partialPackage {
+	options := &ReadOptions{}
+	for _, opt := range opts {
+		opt.Apply(options)
+	}
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
 	return partialPackage{
-		reader:    newReader(fs, basePath, "json"),
-		resources: ccmap.New[*schema.ResourceSpec](),
-		functions: ccmap.New[*schema.FunctionSpec](),
-		types:     ccmap.New[*schema.ComplexTypeSpec](),
+		fs:          fs,
+		basePath:    basePath,
+		concurrency: concurrency,
+		resources:   ccmap.New[*schema.ResourceSpec](),
+		functions:   ccmap.New[*schema.FunctionSpec](),
+		types:       ccmap.New[*schema.ComplexTypeSpec](),
 	}
 }
 
+// ReadOptionConcurrency limits the number of specs read in parallel when loading all resources, functions or types.
+// Defaults to GOMAXPROCS.
+func ReadOptionConcurrency(concurrency int) ReadOption {
+	return readOptionFunc(func(opts *ReadOptions) {
+		opts.Concurrency = concurrency
+	})
+}
+
+type ReadOption interface {
+	Apply(*ReadOptions)
+}
+
+type ReadOptions struct {
+	Concurrency int
+}
+
+type readOptionFunc func(*ReadOptions)
+
+func (o readOptionFunc) Apply(opts *ReadOptions) {
+	o(opts)
+}
+
 func ReadPackageSpec(path string) (*schema.PackageSpec, error) {
 	partialPkg := NewLocalPartialPackage(path)
 	return partialPkg.ReadPackageSpec()
 }
 
 type partialPackage struct {
-	core *schema.PackageSpec
+	core atomic.Pointer[schema.PackageSpec]
+
+	fs       fs.FS
+	basePath string
+	// reader is configured from the manifest when it's first needed.
+	reader atomic.Pointer[reader]
+	// concurrency is the maximum number of specs read in parallel by each bulk load.
+	concurrency int
 
-	reader         reader
 	resourceTokens atomic.Pointer[tokenMappings]
 	resources      ccmap.ConcurrentMap[string, *schema.ResourceSpec]
 
@@ -49,13 +95,13 @@ type partialPackage struct {
 	types      ccmap.ConcurrentMap[string, *schema.ComplexTypeSpec]
 }
 
-func NewLocalPartialPackageWithMetadata[ResourceMeta any, FunctionMeta any, TypeMeta any](basePath string) partialPackageWithMetadata[ResourceMeta, FunctionMeta, TypeMeta] {
-	return NewPartialPackageWithMetadata[ResourceMeta, FunctionMeta, TypeMeta](os.DirFS(basePath), ".")
+func NewLocalPartialPackageWithMetadata[ResourceMeta any, FunctionMeta any, TypeMeta any](basePath string, opts ...ReadOption) *partialPackageWithMetadata[ResourceMeta, FunctionMeta, TypeMeta] {
+	return NewPartialPackageWithMetadata[ResourceMeta, FunctionMeta, TypeMeta](os.DirFS(basePath), ".", opts...)
 }
 
-func NewPartialPackageWithMetadata[ResourceMeta any, FunctionMeta any, TypeMeta any](fs fs.FS, basePath string) partialPackageWithMetadata[ResourceMeta, FunctionMeta, TypeMeta] {
-	return partialPackageWithMetadata[ResourceMeta, FunctionMeta, TypeMeta]{
-		partialPackage: NewPartialPackage(fs, basePath),
+func NewPartialPackageWithMetadata[ResourceMeta any, FunctionMeta any, TypeMeta any](fs fs.FS, basePath string, opts ...ReadOption) *partialPackageWithMetadata[ResourceMeta, FunctionMeta, TypeMeta] {
+	return &partialPackageWithMetadata[ResourceMeta, FunctionMeta, TypeMeta]{
+		partialPackage: newPartialPackage(fs, basePath, opts...),
 		resourceMeta:   ccmap.New[*ResourceMeta](),
 		functionMeta:   ccmap.New[*FunctionMeta](),
 		typeMeta:       ccmap.New[*TypeMeta](),
@@ -74,106 +120,144 @@ type tokenMappings struct {
 	list    []string
 }
 
+// ReadPackageSpec reads the whole package. The returned spec and its Resources, Functions and Types maps are new, so
+// the caller may set fields or add and remove specs, but nested values such as the core's Language and Config maps and
+// the maps within each spec are shared with the reader's cache and must not be modified.
 func (p *partialPackage) ReadPackageSpec() (*schema.PackageSpec, error) {
+	return p.ReadPackageSpecContext(context.Background())
+}
+
+// ReadPackageSpecContext reads the whole package, returning ctx.Err() if the context is cancelled before all specs are read.
+func (p *partialPackage) ReadPackageSpecContext(ctx context.Context) (*schema.PackageSpec, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	core, err := p.getCore()
 	if err != nil {
 		return nil, err
 	}
+	pkg := *core
+
+	resourceTokens, resourceTokensErr := p.GetResourceTokens()
+	functionTokens, functionTokensErr := p.GetFunctionTokens()
+	typeTokens, typeTokensErr := p.GetTypeTokens()
+	if err := errors.Join(resourceTokensErr, functionTokensErr, typeTokensErr); err != nil {
+		return nil, err
+	}
+	// The three loads share one limiter so no more than p.concurrency specs are read at once in total.
+	lim := newLimiter(min(p.concurrency, len(resourceTokens)+len(functionTokens)+len(typeTokens)))
 
 	var waitGroup sync.WaitGroup
 	var resourceLoadErr, functionLoadErr, typesLoadErr error
 
 	waitGroup.Add(1)
 	go func() {
-		core.Resources, resourceLoadErr = p.GetResources()
+		pkg.Resources, resourceLoadErr = getAllLimited(ctx, resourceTokens, lim, p.GetResourceContext)
 		waitGroup.Done()
 	}()
 
 	waitGroup.Add(1)
 	go func() {
-		core.Functions, functionLoadErr = p.GetFunctions()
+		pkg.Functions, functionLoadErr = getAllLimited(ctx, functionTokens, lim, p.GetFunctionContext)
 		waitGroup.Done()
 	}()
 
 	waitGroup.Add(1)
 	go func() {
-		core.Types, typesLoadErr = p.GetTypes()
+		pkg.Types, typesLoadErr = getAllLimited(ctx, typeTokens, lim, p.GetTypeContext)
 		waitGroup.Done()
 	}()
 
 	waitGroup.Wait()
 
-	if resourceLoadErr != nil {
-		return nil, resourceLoadErr
-	}
-	if functionLoadErr != nil {
-		return nil, functionLoadErr
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	if typesLoadErr != nil {
-		return nil, typesLoadErr
+	if err := errors.Join(resourceLoadErr, functionLoadErr, typesLoadErr); err != nil {
+		return nil, err
 	}
-	return core, nil
+	return &pkg, nil
 }
 
-func (p *partialPackage) getCore() (*schema.PackageSpec, error) {
-	if p.core != nil {
-		return p.core, nil
+// getReader returns the reader configured from the manifest, reading the manifest on first use. Errors aren't cached,
+// so a failed read is retried by the next call.
+func (p *partialPackage) getReader() (*reader, error) {
+	if r := p.reader.Load(); r != nil {
+		return r, nil
 	}
-	var core schema.PackageSpec
-	if err := p.reader.readData("core", &core); err != nil {
+	r, err := newManifestReader(p.fs, p.basePath)
+	if err != nil {
 		return nil, err
 	}
-	p.core = &core
-	return p.core, nil
+	if !p.reader.CompareAndSwap(nil, r) {
+		// Use the first loaded reader if another goroutine loaded it first.
+		return p.reader.Load(), nil
+	}
+	return r, nil
 }
 
-func (p *partialPackage) GetResources() (map[string]schema.ResourceSpec, error) {
-	resourceTokens, err := p.GetResourceTokens()
+// getCore returns the cached core spec, which must not be modified.
+func (p *partialPackage) getCore() (*schema.PackageSpec, error) {
+	if core := p.core.Load(); core != nil {
+		return core, nil
+	}
+	r, err := p.getReader()
 	if err != nil {
 		return nil, err
 	}
-	var lastErr error
-	resources := make([]*schema.ResourceSpec, len(resourceTokens))
-	var waitGroup sync.WaitGroup
-	for i, v := range resourceTokens {
-		waitGroup.Add(1)
-		go func(index int, token string) {
-			res, err := p.GetResource(token)
-			if err != nil {
-				lastErr = err
-			}
-			resources[index] = res
-			waitGroup.Done()
-		}(i, v)
+	var core schema.PackageSpec
+	if err := r.readData("core", &core); err != nil {
+		return nil, err
 	}
-	waitGroup.Wait()
-	if lastErr != nil {
-		return nil, lastErr
+	if !p.core.CompareAndSwap(nil, &core) {
+		// Use the first loaded core if another goroutine loaded it first.
+		return p.core.Load(), nil
 	}
+	return &core, nil
+}
+
+func (p *partialPackage) GetResources() (map[string]schema.ResourceSpec, error) {
+	return p.GetResourcesContext(context.Background())
+}
 
-	resourceMap := make(map[string]schema.ResourceSpec, len(resourceTokens))
-	for i, v := range resourceTokens {
-		resourceMap[v] = *resources[i]
+// GetResourcesContext reads all resources, stopping early and returning ctx.Err() if the context is cancelled.
+func (p *partialPackage) GetResourcesContext(ctx context.Context) (map[string]schema.ResourceSpec, error) {
+	tokens, err := p.GetResourceTokens()
+	if err != nil {
+		return nil, err
 	}
-	return resourceMap, nil
+	return getAll(ctx, tokens, p.concurrency, p.GetResourceContext)
 }
 
 func (p *partialPackage) GetResource(token string) (*schema.ResourceSpec, error) {
+	return p.GetResourceContext(context.Background(), token)
+}
+
+// GetResourceContext reads a single resource, returning ctx.Err() if the context is cancelled before it's read.
+func (p *partialPackage) GetResourceContext(ctx context.Context, token string) (*schema.ResourceSpec, error) {
 	if spec, ok := p.resources.Get(token); ok {
 		return spec, nil
 	}
-	path, err := getPath(token, "resources")
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path, err := p.specPath(token, "resources")
 	if err != nil {
 		return nil, err
 	}
 	var spec schema.ResourceSpec
-	description, err := p.reader.readSpec(path, &spec)
+	r, err := p.getReader()
 	if err != nil {
 		return nil, err
 	}
+	description, props, err := r.readSpec(path, &spec)
+	if err != nil {
+		return nil, r.specError(err, token, "resources", path)
+	}
 	if description != nil {
 		spec.Description = *description
 	}
+	props.restoreResource(&spec)
 	if !p.resources.SetIfAbsent(token, &spec) {
 		// Use the first written spec if another goroutine wrote the spec first.
 		if spec, ok := p.resources.Get(token); ok {
@@ -183,59 +267,58 @@ func (p *partialPackage) GetResource(token string) (*schema.ResourceSpec, error)
 	return &spec, nil
 }
 
-// GetResourceTokens returns the resource tokens for the package sorted alphabetically.
+// GetResourceTokens returns the resource tokens for the package sorted alphabetically, including tokens which only
+// have metadata.
 func (p *partialPackage) GetResourceTokens() ([]string, error) {
 	mappings, err := p.getResourceTokenMappings()
-	return mappings.list, err
+	if err != nil {
+		return nil, err
+	}
+	return mappings.list, nil
 }
 
 func (p *partialPackage) GetFunctions() (map[string]schema.FunctionSpec, error) {
-	functionTokens, err := p.GetFunctionTokens()
+	return p.GetFunctionsContext(context.Background())
+}
+
+// GetFunctionsContext reads all functions, stopping early and returning ctx.Err() if the context is cancelled.
+func (p *partialPackage) GetFunctionsContext(ctx context.Context) (map[string]schema.FunctionSpec, error) {
+	tokens, err := p.GetFunctionTokens()
 	if err != nil {
 		return nil, err
 	}
-	var lastErr error
-	functions := make([]*schema.FunctionSpec, len(functionTokens))
-	var waitGroup sync.WaitGroup
-	for i, v := range functionTokens {
-		waitGroup.Add(1)
-		go func(index int, token string) {
-			fn, err := p.GetFunction(token)
-			if err != nil {
-				lastErr = err
-			}
-			functions[index] = fn
-			waitGroup.Done()
-		}(i, v)
-	}
-	waitGroup.Wait()
-	if lastErr != nil {
-		return nil, lastErr
-	}
-
-	functionMap := make(map[string]schema.FunctionSpec, len(functionTokens))
-	for i, v := range functionTokens {
-		functionMap[v] = *functions[i]
-	}
-	return functionMap, nil
+	return getAll(ctx, tokens, p.concurrency, p.GetFunctionContext)
 }
 
 func (p *partialPackage) GetFunction(token string) (*schema.FunctionSpec, error) {
+	return p.GetFunctionContext(context.Background(), token)
+}
+
+// GetFunctionContext reads a single function, returning ctx.Err() if the context is cancelled before it's read.
+func (p *partialPackage) GetFunctionContext(ctx context.Context, token string) (*schema.FunctionSpec, error) {
 	if spec, ok := p.functions.Get(token); ok {
 		return spec, nil
 	}
-	path, err := getPath(token, "functions")
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path, err := p.specPath(token, "functions")
 	if err != nil {
 		return nil, err
 	}
 	var spec schema.FunctionSpec
-	description, err := p.reader.readSpec(path, &spec)
+	r, err := p.getReader()
 	if err != nil {
 		return nil, err
 	}
+	description, props, err := r.readSpec(path, &spec)
+	if err != nil {
+		return nil, r.specError(err, token, "functions", path)
+	}
 	if description != nil {
 		spec.Description = *description
 	}
+	props.restoreFunction(&spec)
 	if !p.functions.SetIfAbsent(token, &spec) {
 		// Use the first written spec if another goroutine wrote the spec first.
 		if spec, ok := p.functions.Get(token); ok {
@@ -247,56 +330,54 @@ func (p *partialPackage) GetFunction(token string) (*schema.FunctionSpec, error)
 
 func (p *partialPackage) GetFunctionTokens() ([]string, error) {
 	mappings, err := p.getFunctionTokenMappings()
-	return mappings.list, err
+	if err != nil {
+		return nil, err
+	}
+	return mappings.list, nil
 }
 
 func (p *partialPackage) GetTypes() (map[string]schema.ComplexTypeSpec, error) {
-	typeTokens, err := p.GetTypeTokens()
+	return p.GetTypesContext(context.Background())
+}
+
+// GetTypesContext reads all types, stopping early and returning ctx.Err() if the context is cancelled.
+func (p *partialPackage) GetTypesContext(ctx context.Context) (map[string]schema.ComplexTypeSpec, error) {
+	tokens, err := p.GetTypeTokens()
 	if err != nil {
 		return nil, err
 	}
-	var lastErr error
-	types := make([]*schema.ComplexTypeSpec, len(typeTokens))
-	var waitGroup sync.WaitGroup
-	for i, v := range typeTokens {
-		waitGroup.Add(1)
-		go func(index int, token string) {
-			typ, err := p.GetType(token)
-			if err != nil {
-				lastErr = err
-			}
-			types[index] = typ
-			waitGroup.Done()
-		}(i, v)
-	}
-	waitGroup.Wait()
-	if lastErr != nil {
-		return nil, lastErr
-	}
-
-	typeMap := make(map[string]schema.ComplexTypeSpec, len(typeTokens))
-	for i, v := range typeTokens {
-		typeMap[v] = *types[i]
-	}
-	return typeMap, nil
+	return getAll(ctx, tokens, p.concurrency, p.GetTypeContext)
 }
 
 func (p *partialPackage) GetType(token string) (*schema.ComplexTypeSpec, error) {
+	return p.GetTypeContext(context.Background(), token)
+}
+
+// GetTypeContext reads a single type, returning ctx.Err() if the context is cancelled before it's read.
+func (p *partialPackage) GetTypeContext(ctx context.Context, token string) (*schema.ComplexTypeSpec, error) {
 	if spec, ok := p.types.Get(token); ok {
 		return spec, nil
 	}
-	path, err := getPath(token, "types")
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path, err := p.specPath(token, "types")
 	if err != nil {
 		return nil, err
 	}
 	var spec schema.ComplexTypeSpec
-	description, err := p.reader.readSpec(path, &spec)
+	r, err := p.getReader()
 	if err != nil {
 		return nil, err
 	}
+	description, props, err := r.readSpec(path, &spec)
+	if err != nil {
+		return nil, r.specError(err, token, "types", path)
+	}
 	if description != nil {
 		spec.Description = *description
 	}
+	props.restoreType(&spec)
 	if !p.types.SetIfAbsent(token, &spec) {
 		// Use the first written spec if another goroutine wrote the spec first.
 		if spec, ok := p.types.Get(token); ok {
@@ -308,7 +389,57 @@ func (p *partialPackage) GetType(token string) (*schema.ComplexTypeSpec, error)
 
 func (p *partialPackage) GetTypeTokens() ([]string, error) {
 	mappings, err := p.getTypeTokenMappings()
-	return mappings.list, err
+	if err != nil {
+		return nil, err
+	}
+	return mappings.list, nil
+}
+
+// specPath returns the path of the spec files for a token, excluding the extension, as recorded in the index for the
+// kind. Tokens which aren't in the index are rejected without touching the filesystem.
+func (p *partialPackage) specPath(token, kind string) (string, error) {
+	var mappings *tokenMappings
+	var err error
+	switch kind {
+	case "resources":
+		mappings, err = p.getResourceTokenMappings()
+	case "functions":
+		mappings, err = p.getFunctionTokenMappings()
+	case "types":
+		mappings, err = p.getTypeTokenMappings()
+	default:
+		return "", fmt.Errorf("unknown kind: %s", kind)
+	}
+	if err != nil {
+		return "", err
+	}
+	path, ok := mappings.mapping[token]
+	if !ok {
+		return "", &TokenNotFoundError{Token: token, Kind: kind}
+	}
+	if path == "" {
+		// Fall back to the layout from the manifest if the index doesn't record a path.
+		r, err := p.getReader()
+		if err != nil {
+			return "", err
+		}
+		layout, ok := LookupPathLayout(r.layout)
+		if !ok {
+			return "", fmt.Errorf("%s %q has no path in the index and layout %q is unknown; register it with RegisterPathLayout", kindName(kind), token, r.layout)
+		}
+		return layout.Path(token, kind)
+	}
+	return path, nil
+}
+
+// specError adds token context to an error returned while reading the spec at path for a token listed in the index.
+// Tokens which only have metadata are listed in the index too, so a missing spec is reported as not found rather than
+// corrupt when the token's metadata file exists.
+func (r *reader) specError(err error, token, kind, path string) error {
+	if errors.Is(err, fs.ErrNotExist) && r.hasData(path+".meta") {
+		return &TokenNotFoundError{Token: token, Kind: kind}
+	}
+	return tokenError(err, token, kind, r.dataPath(path))
 }
 
 // getResourceTokenMappings returns the resource token mappings and a sorted list of resource tokens.
@@ -321,9 +452,13 @@ func (p *partialPackage) getTokenMappings(tokenMappingsPtr *atomic.Pointer[token
 		return mappings, nil
 	}
 
+	r, err := p.getReader()
+	if err != nil {
+		return nil, err
+	}
 	mappings := tokenMappings{}
 
-	if err := p.reader.readData(pathExExt, &mappings.mapping); err != nil {
+	if err := r.readData(pathExExt, &mappings.mapping); err != nil {
 		return nil, err
 	}
 	mappings.list = make([]string, 0, len(mappings.mapping))
@@ -348,83 +483,200 @@ func (p *partialPackage) getFunctionTokenMappings() (*tokenMappings, error) {
 }
 
 func (p *partialPackageWithMetadata[ResourceMeta, FunctionMeta, TypeMeta]) GetResourceMeta(token string) (*ResourceMeta, error) {
-	return getMetadata(&p.resourceMeta, &p.reader, "resources", token)
+	return getMetadata(&p.resourceMeta, &p.partialPackage, "resources", token)
 }
 
 func (p *partialPackageWithMetadata[ResourceMeta, FunctionMeta, TypeMeta]) GetFunctionMeta(token string) (*FunctionMeta, error) {
-	return getMetadata(&p.functionMeta, &p.reader, "functions", token)
+	return getMetadata(&p.functionMeta, &p.partialPackage, "functions", token)
 }
 
 func (p *partialPackageWithMetadata[ResourceMeta, FunctionMeta, TypeMeta]) GetTypeMeta(token string) (*TypeMeta, error) {
-	return getMetadata(&p.typeMeta, &p.reader, "types", token)
+	return getMetadata(&p.typeMeta, &p.partialPackage, "types", token)
 }
 
 type reader struct {
 	fs       fs.FS
 	basePath string
 	format   string
+	// layoutVersion is the version of the layout from the manifest.
+	layoutVersion int
+	// propertyDescriptions is set when property descriptions may have been written to separate files.
+	propertyDescriptions bool
+	// examples is set when the code of examples may have been written to separate files.
+	examples bool
+	// layout is the name of the PathLayout from the manifest, used for tokens whose path isn't recorded in the index.
+	layout string
 }
 
 func newReader(fs fs.FS, basePath, format string) reader {
 	return reader{fs: fs, basePath: basePath, format: format}
 }
 
-func (r *reader) readSpec(path string, data any) (description *string, err error) {
+// newManifestReader creates a reader configured from the manifest in the split directory.
+func newManifestReader(fs fs.FS, basePath string) (*reader, error) {
+	manifest, err := ReadManifest(fs, basePath)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	r := newReader(fs, basePath, manifest.Format)
+	r.layout = manifest.Layout
+	r.layoutVersion = manifest.LayoutVersion
+	r.propertyDescriptions = manifest.PropertyDescriptions
+	r.examples = manifest.Examples
+	return &r, nil
+}
+
+// detectFormat returns the format of the split files by checking which core file exists, defaulting to "json".
+func detectFormat(fsys fs.FS, basePath string) string {
+	if _, err := fs.Stat(fsys, filepath.Join(basePath, "core.yaml")); err == nil {
+		return "yaml"
+	}
+	return "json"
+}
+
+// readSpec reads a spec along with its description and property descriptions, if they were written to separate files.
+func (r *reader) readSpec(path string, data any) (description *string, props propertyDescriptions, err error) {
 	descriptionBytes, err := r.ReadFile(path + ".md")
 	if err != nil {
 		if !os.IsNotExist(err) {
-			return nil, err
+			return nil, nil, err
 		}
 	} else {
 		descriptionStr := string(descriptionBytes)
+		if r.layoutVersion >= 2 {
+			descriptionStr = strings.TrimSuffix(descriptionStr, "\n")
+		}
+		if r.examples {
+			descriptionStr, err = r.readExamples(path, descriptionStr)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
 		description = &descriptionStr
 	}
-	err = r.readData(path, data)
-	if err != nil && !os.IsNotExist(err) {
-		return nil, err
+	if err := r.readData(path, data); err != nil {
+		return nil, nil, err
 	}
-	if data == nil {
-		return nil, nil
+	props, err = r.readPropertyDescriptions(path)
+	if err != nil {
+		return nil, nil, err
 	}
-	return description, nil
+	return description, props, nil
 }
 
 func (r *reader) readData(pathExExt string, data any) error {
+	path := r.dataPath(pathExExt)
 	if r.format == "json" {
-		bytes, err := r.ReadFile(pathExExt + ".json")
+		bytes, err := r.ReadFile(path)
 		if err != nil {
 			return err
 		}
-		return json.Unmarshal(bytes, data)
+		if err := json.Unmarshal(bytes, data); err != nil {
+			return &SpecDecodeError{Path: path, Err: err}
+		}
+		return nil
 	}
 	if r.format == "yaml" {
-		bytes, err := r.ReadFile(pathExExt + ".yaml")
+		bytes, err := r.ReadFile(path)
 		if err != nil {
 			return err
 		}
-		return yaml.Unmarshal(bytes, data)
+		if err := unmarshalYAML(bytes, data); err != nil {
+			return &SpecDecodeError{Path: path, Err: err}
+		}
+		return nil
 	}
 	return fmt.Errorf("unsupported format: %s", r.format)
 }
 
+// dataPath returns the path of a data file including the extension for the reader's format.
+func (r *reader) dataPath(pathExExt string) string {
+	return pathExExt + "." + r.format
+}
+
+// hasData reports whether the data file for pathExExt exists.
+func (r *reader) hasData(pathExExt string) bool {
+	_, err := fs.Stat(r.fs, filepath.Join(r.basePath, r.dataPath(pathExExt)))
+	return err == nil
+}
+
 func (r *reader) ReadFile(path string) ([]byte, error) {
 	return fs.ReadFile(r.fs, filepath.Join(r.basePath, path))
 }
 
-func getMetadata[T any](cache *ccmap.ConcurrentMap[string, *T], reader *reader, kind, token string) (*T, error) {
+// getAll loads the specs for all tokens, reading up to concurrency specs at once, returning all errors encountered.
+// Workers stop picking up new tokens once the context is cancelled.
+func getAll[T any](ctx context.Context, tokens []string, concurrency int, get func(ctx context.Context, token string) (*T, error)) (map[string]T, error) {
+	return getAllLimited(ctx, tokens, newLimiter(min(concurrency, len(tokens))), get)
+}
+
+// limiter bounds the number of specs read at once, and can be shared by several loads running at the same time.
+type limiter chan struct{}
+
+func newLimiter(concurrency int) limiter {
+	return make(limiter, max(concurrency, 1))
+}
+
+// getAllLimited loads the specs for all tokens using a pool of workers which each hold the limiter while reading a spec.
+// Tokens which aren't found, because only their metadata was written, are left out of the result.
+func getAllLimited[T any](ctx context.Context, tokens []string, lim limiter, get func(ctx context.Context, token string) (*T, error)) (map[string]T, error) {
+	specs := make([]*T, len(tokens))
+	errs := make([]error, len(tokens))
+	var next atomic.Int64
+	var waitGroup sync.WaitGroup
+	for w := 0; w < min(cap(lim), len(tokens)); w++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for {
+				index := int(next.Add(1) - 1)
+				if index >= len(tokens) || ctx.Err() != nil {
+					return
+				}
+				lim <- struct{}{}
+				specs[index], errs[index] = get(ctx, tokens[index])
+				<-lim
+				if errors.Is(errs[index], ErrTokenNotFound) {
+					errs[index] = nil
+				}
+			}
+		}()
+	}
+	waitGroup.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	specMap := make(map[string]T, len(tokens))
+	for i, v := range tokens {
+		if specs[i] != nil {
+			specMap[v] = *specs[i]
+		}
+	}
+	return specMap, nil
+}
+
+func getMetadata[T any](cache *ccmap.ConcurrentMap[string, *T], p *partialPackage, kind, token string) (*T, error) {
 	if spec, ok := cache.Get(token); ok {
 		return spec, nil
 	}
-	path, err := getPath(token, kind)
+	path, err := p.specPath(token, kind)
 	if err != nil {
 		return nil, err
 	}
 
-	var spec T
-	err = reader.readData(path+".meta", &spec)
-	if err != nil && !os.IsNotExist(err) {
+	r, err := p.getReader()
+	if err != nil {
 		return nil, err
 	}
+	var spec T
+	err = r.readData(path+".meta", &spec)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, tokenError(err, token, kind, r.dataPath(path+".meta"))
+	}
 	if !cache.SetIfAbsent(token, &spec) {
 		// Use the first written spec if another goroutine wrote the spec first.
 		if spec, ok := cache.Get(token); ok {
@@ -0,0 +1,259 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+)
+
+// ReferenceReport lists the problems with the local `$ref`s between the specs of a package.
+type ReferenceReport struct {
+	// Dangling lists references to types or resources which aren't in the package. References in the core come first,
+	// followed by those in resources, functions and types, each sorted by token.
+	Dangling []DanglingReference `json:"dangling,omitempty"`
+	// UnusedTypes lists the types which can't be reached from any resource, function, the provider or config, sorted
+	// by token.
+	UnusedTypes []string `json:"unusedTypes,omitempty"`
+	// Cycles lists each group of types which reference each other, directly or through other types. Each group is
+	// sorted, and the groups are sorted by their first token.
+	Cycles [][]string `json:"cycles,omitempty"`
+}
+
+// DanglingReference is a `$ref` to a type or resource which isn't in the package.
+type DanglingReference struct {
+	// Kind is one of "resources", "functions" or "types", or "core" for the provider and config.
+	Kind string `json:"kind"`
+	// Token is the spec containing the reference, or empty for the core.
+	Token string `json:"token,omitempty"`
+	// Path is the path of the `$ref` field within the spec's JSON.
+	Path FieldPath `json:"path"`
+	Ref  string    `json:"ref"`
+}
+
+// Empty reports whether there are no reference problems.
+func (r *ReferenceReport) Empty() bool {
+	return len(r.Dangling) == 0 && len(r.UnusedTypes) == 0 && len(r.Cycles) == 0
+}
+
+// CheckReferences walks every type in the package's resources, functions, types, provider and config, resolving
+// references to this package (e.g. `#/types/aws:ec2/Tag:Tag`) against the package's resources and types. References to
+// other documents, such as `pulumi.json#/Any`, aren't checked.
+func (p *partialPackage) CheckReferences(ctx context.Context) (*ReferenceReport, error) {
+	core, err := p.getCore()
+	if err != nil {
+		return nil, err
+	}
+	// Resolve references against the specs which were loaded, as the indexes also list tokens which only have metadata.
+	resources, resourcesErr := p.GetResourcesContext(ctx)
+	functions, functionsErr := p.GetFunctionsContext(ctx)
+	types, typesErr := p.GetTypesContext(ctx)
+	if err := errors.Join(resourcesErr, functionsErr, typesErr); err != nil {
+		return nil, err
+	}
+	resourceTokens := sortedKeys(resources)
+	typeTokens := sortedKeys(types)
+
+	c := &refChecker{
+		report:         &ReferenceReport{},
+		resourceTokens: resourceTokens,
+		typeTokens:     typeTokens,
+		used:           map[string]bool{},
+		typeRefs:       map[string][]string{},
+	}
+	c.checkResource("core", "", FieldPath{"provider"}, core.Provider)
+	c.checkProperties("core", "", FieldPath{"config", "variables"}, core.Config.Variables)
+	for _, token := range resourceTokens {
+		c.checkResource("resources", token, nil, resources[token])
+	}
+	for _, token := range sortedKeys(functions) {
+		spec := functions[token]
+		if spec.Inputs != nil {
+			c.checkProperties("functions", token, FieldPath{"inputs", "properties"}, spec.Inputs.Properties)
+		}
+		if spec.Outputs != nil {
+			c.checkProperties("functions", token, FieldPath{"outputs", "properties"}, spec.Outputs.Properties)
+		}
+		if spec.ReturnType != nil {
+			if spec.ReturnType.ObjectTypeSpec != nil {
+				c.checkProperties("functions", token, FieldPath{"outputs", "properties"}, spec.ReturnType.ObjectTypeSpec.Properties)
+			}
+			if spec.ReturnType.TypeSpec != nil {
+				c.checkType("functions", token, FieldPath{"outputs"}, spec.ReturnType.TypeSpec)
+			}
+		}
+	}
+	for _, token := range typeTokens {
+		c.checkProperties("types", token, FieldPath{"properties"}, types[token].Properties)
+	}
+
+	c.findUnusedTypes()
+	c.findCycles()
+	return c.report, nil
+}
+
+// refChecker collects the reference problems of a package, along with the graph of references between its types.
+type refChecker struct {
+	report *ReferenceReport
+	// resourceTokens and typeTokens are sorted.
+	resourceTokens []string
+	typeTokens     []string
+	// used holds the types referenced by anything other than a type.
+	used map[string]bool
+	// typeRefs maps each type to the types it references, which may contain duplicates.
+	typeRefs map[string][]string
+}
+
+func (c *refChecker) checkResource(kind, token string, path FieldPath, spec schema.ResourceSpec) {
+	c.checkProperties(kind, token, append(slices.Clip(path), "properties"), spec.Properties)
+	c.checkProperties(kind, token, append(slices.Clip(path), "inputProperties"), spec.InputProperties)
+	if spec.StateInputs != nil {
+		c.checkProperties(kind, token, append(slices.Clip(path), "stateInputs", "properties"), spec.StateInputs.Properties)
+	}
+}
+
+func (c *refChecker) checkProperties(kind, token string, path FieldPath, properties map[string]schema.PropertySpec) {
+	for _, name := range sortedKeys(properties) {
+		spec := properties[name].TypeSpec
+		c.checkType(kind, token, append(slices.Clip(path), name), &spec)
+	}
+}
+
+func (c *refChecker) checkType(kind, token string, path FieldPath, spec *schema.TypeSpec) {
+	if spec.Ref != "" {
+		c.checkRef(kind, token, append(slices.Clip(path), "$ref"), spec.Ref)
+	}
+	if spec.Items != nil {
+		c.checkType(kind, token, append(slices.Clip(path), "items"), spec.Items)
+	}
+	if spec.AdditionalProperties != nil {
+		c.checkType(kind, token, append(slices.Clip(path), "additionalProperties"), spec.AdditionalProperties)
+	}
+	for i := range spec.OneOf {
+		c.checkType(kind, token, append(slices.Clip(path), "oneOf", strconv.Itoa(i)), &spec.OneOf[i])
+	}
+	if spec.Discriminator != nil {
+		for _, value := range sortedKeys(spec.Discriminator.Mapping) {
+			c.checkRef(kind, token, append(slices.Clip(path), "discriminator", "mapping", value), spec.Discriminator.Mapping[value])
+		}
+	}
+}
+
+func (c *refChecker) checkRef(kind, token string, path FieldPath, ref string) {
+	refKind, refToken, ok := parseLocalRef(ref)
+	if !ok {
+		return
+	}
+	switch {
+	case refKind == "provider" && refToken == "":
+		return
+	case refKind == "resources" && containsSorted(c.resourceTokens, refToken):
+		return
+	case refKind == "types" && containsSorted(c.typeTokens, refToken):
+		if kind == "types" {
+			c.typeRefs[token] = append(c.typeRefs[token], refToken)
+		} else {
+			c.used[refToken] = true
+		}
+		return
+	}
+	c.report.Dangling = append(c.report.Dangling, DanglingReference{Kind: kind, Token: token, Path: path, Ref: ref})
+}
+
+// findUnusedTypes reports the types which can't be reached from the resources, functions, provider or config.
+func (c *refChecker) findUnusedTypes() {
+	reachable := map[string]bool{}
+	var visit func(token string)
+	visit = func(token string) {
+		if reachable[token] {
+			return
+		}
+		reachable[token] = true
+		for _, ref := range c.typeRefs[token] {
+			visit(ref)
+		}
+	}
+	for token := range c.used {
+		visit(token)
+	}
+	for _, token := range c.typeTokens {
+		if !reachable[token] {
+			c.report.UnusedTypes = append(c.report.UnusedTypes, token)
+		}
+	}
+}
+
+// findCycles reports the strongly connected components of the type graph which contain a cycle, using Tarjan's
+// algorithm.
+func (c *refChecker) findCycles() {
+	index := map[string]int{}
+	lowLink := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var connect func(token string)
+	connect = func(token string) {
+		index[token] = len(index)
+		lowLink[token] = index[token]
+		stack = append(stack, token)
+		onStack[token] = true
+
+		selfRef := false
+		for _, ref := range c.typeRefs[token] {
+			if ref == token {
+				selfRef = true
+			}
+			if _, visited := index[ref]; !visited {
+				connect(ref)
+				lowLink[token] = min(lowLink[token], lowLink[ref])
+			} else if onStack[ref] {
+				lowLink[token] = min(lowLink[token], index[ref])
+			}
+		}
+		if lowLink[token] != index[token] {
+			return
+		}
+
+		var component []string
+		for {
+			member := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[member] = false
+			component = append(component, member)
+			if member == token {
+				break
+			}
+		}
+		if len(component) > 1 || selfRef {
+			slices.Sort(component)
+			c.report.Cycles = append(c.report.Cycles, component)
+		}
+	}
+	for _, token := range c.typeTokens {
+		if _, visited := index[token]; !visited {
+			connect(token)
+		}
+	}
+	slices.SortFunc(c.report.Cycles, func(a, b []string) int {
+		return strings.Compare(a[0], b[0])
+	})
+}
+
+// parseLocalRef splits a reference to this package, e.g. `#/types/aws:ec2/Tag:Tag`, into its kind and unescaped
+// token. ok is false for references to other documents, such as `pulumi.json#/Any`.
+func parseLocalRef(ref string) (kind, token string, ok bool) {
+	fragment, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return "", "", false
+	}
+	kind, token, _ = strings.Cut(fragment, "/")
+	if unescaped, err := url.PathUnescape(token); err == nil {
+		token = unescaped
+	}
+	return kind, token, true
+}
@@ -0,0 +1,128 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/pulumi/splitschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func refProperty(ref string) schema.PropertySpec {
+	return schema.PropertySpec{TypeSpec: schema.TypeSpec{Ref: ref}}
+}
+
+func TestCheckReferences(t *testing.T) {
+	pkg := &schema.PackageSpec{
+		Name: "test",
+		Provider: schema.ResourceSpec{
+			InputProperties: map[string]schema.PropertySpec{"args": refProperty("#/types/test:index:ProviderArgs")},
+		},
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Resource": {
+				ObjectTypeSpec: schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{
+						"any":    refProperty("pulumi.json#/Any"),
+						"parent": refProperty("#/types/test:index:Parent"),
+						"tags": {TypeSpec: schema.TypeSpec{
+							Type:                 "object",
+							AdditionalProperties: &schema.TypeSpec{Ref: "#/types/test:index:Removed"},
+						}},
+					},
+				},
+			},
+		},
+		Functions: map[string]schema.FunctionSpec{
+			"test:index:getResource": {
+				Outputs: &schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{"resource": refProperty("#/resources/test:index:Resource")},
+				},
+			},
+		},
+		Types: map[string]schema.ComplexTypeSpec{
+			"test:index:Parent": {ObjectTypeSpec: schema.ObjectTypeSpec{
+				Type: "object",
+				Properties: map[string]schema.PropertySpec{
+					"children": {TypeSpec: schema.TypeSpec{Type: "array", Items: &schema.TypeSpec{Ref: "#/types/test:index:Child"}}},
+				},
+			}},
+			"test:index:Child": {ObjectTypeSpec: schema.ObjectTypeSpec{
+				Type:       "object",
+				Properties: map[string]schema.PropertySpec{"parent": refProperty("#/types/test:index:Parent")},
+			}},
+			"test:index:ProviderArgs": {ObjectTypeSpec: schema.ObjectTypeSpec{Type: "object"}},
+			"test:index:Unused": {ObjectTypeSpec: schema.ObjectTypeSpec{
+				Type:       "object",
+				Properties: map[string]schema.PropertySpec{"other": refProperty("#/resources/test:index:Missing")},
+			}},
+		},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, pkg))
+
+	report, err := splitschema.NewLocalPartialPackage(dir).CheckReferences(context.Background())
+	require.NoError(t, err)
+	assert.False(t, report.Empty())
+	assert.Equal(t, []splitschema.DanglingReference{
+		{
+			Kind:  "resources",
+			Token: "test:index:Resource",
+			Path:  splitschema.FieldPath{"properties", "tags", "additionalProperties", "$ref"},
+			Ref:   "#/types/test:index:Removed",
+		},
+		{
+			Kind:  "types",
+			Token: "test:index:Unused",
+			Path:  splitschema.FieldPath{"properties", "other", "$ref"},
+			Ref:   "#/resources/test:index:Missing",
+		},
+	}, report.Dangling)
+	assert.Equal(t, []string{"test:index:Unused"}, report.UnusedTypes)
+	assert.Equal(t, [][]string{{"test:index:Child", "test:index:Parent"}}, report.Cycles)
+
+	delete(pkg.Types, "test:index:Unused")
+	pkg.Types["test:index:Child"] = schema.ComplexTypeSpec{ObjectTypeSpec: schema.ObjectTypeSpec{Type: "object"}}
+	pkg.Resources["test:index:Resource"].Properties["tags"] = schema.PropertySpec{TypeSpec: schema.TypeSpec{Type: "string"}}
+	dir = t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, pkg))
+
+	report, err = splitschema.NewLocalPartialPackage(dir).CheckReferences(context.Background())
+	require.NoError(t, err)
+	assert.True(t, report.Empty())
+}
+
+func TestCheckReferencesMetadataOnly(t *testing.T) {
+	pkg := &schema.PackageSpec{
+		Name: "test",
+		Functions: map[string]schema.FunctionSpec{
+			"test:index:getResource": {
+				Outputs: &schema.ObjectTypeSpec{
+					Properties: map[string]schema.PropertySpec{"resource": refProperty("#/resources/test:index:Resource")},
+				},
+			},
+		},
+	}
+	// The resource only has metadata, so references to it are dangling.
+	metadata := &splitschema.PackageMetadata{
+		Resources: map[string]any{"test:index:Resource": "meta"},
+		Types:     map[string]any{"test:index:Type": "meta"},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpecWithMetadata(dir, pkg, metadata))
+
+	report, err := splitschema.NewLocalPartialPackage(dir).CheckReferences(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []splitschema.DanglingReference{
+		{
+			Kind:  "functions",
+			Token: "test:index:getResource",
+			Path:  splitschema.FieldPath{"outputs", "properties", "resource", "$ref"},
+			Ref:   "#/resources/test:index:Resource",
+		},
+	}, report.Dangling)
+	assert.Empty(t, report.UnusedTypes)
+}
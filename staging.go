@@ -0,0 +1,352 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+)
+
+// writeStaged calls write with a temporary sibling directory of path, then swaps the staged directory into place
+// once write has succeeded. If write fails, the staging directory is removed and path is left untouched. When path is
+// a symlink, the directory it points to is replaced and the symlink is kept.
+//
+// Entries already in path which weren't written are moved into the new directory, except for stale files recorded by
+// the existing index files when prune is set. The swap renames the existing directory aside before renaming the
+// staged directory into place, so a crash between the two renames leaves the previous directory beside path.
+//
+// Some directories can't be renamed or have no writable parent, such as a mount point in a container. Those are
+// staged within path instead and their entries are swapped one at a time (see swapEntries).
+func writeStaged(path string, prune bool, write func(stagingPath string) error) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	parent := filepath.Dir(path)
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return writeNew(path, write)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	renamed, err := replaceDir(path, info.Mode().Perm(), prune, write)
+	if renamed || err != nil {
+		return err
+	}
+	return replaceEntries(path, prune, write)
+}
+
+// writeNew writes a directory which doesn't exist yet to a sibling staging directory, then renames it into place.
+func writeNew(path string, write func(stagingPath string) error) error {
+	stagingPath, err := os.MkdirTemp(filepath.Dir(path), "."+filepath.Base(path)+".staging-")
+	if err != nil {
+		return fmt.Errorf("create staging directory: %w", err)
+	}
+	err = os.Chmod(stagingPath, 0755)
+	if err == nil {
+		err = write(stagingPath)
+	}
+	if err == nil {
+		err = os.Rename(stagingPath, path)
+	}
+	if err != nil {
+		_ = os.RemoveAll(stagingPath)
+	}
+	return err
+}
+
+// replaceDir writes to a sibling staging directory and swaps it with the existing directory at path. It returns false
+// without an error, having left path untouched, if the sibling can't be created or path can't be renamed aside.
+func replaceDir(path string, mode fs.FileMode, prune bool, write func(stagingPath string) error) (renamed bool, err error) {
+	stagingPath, err := os.MkdirTemp(filepath.Dir(path), "."+filepath.Base(path)+".staging-")
+	if err != nil {
+		if cannotRename(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("create staging directory: %w", err)
+	}
+	keepStaging := false
+	defer func() {
+		if (err != nil || !renamed) && !keepStaging {
+			_ = os.RemoveAll(stagingPath)
+		}
+	}()
+	if err := os.Chmod(stagingPath, mode); err != nil {
+		return false, err
+	}
+	if err := write(stagingPath); err != nil {
+		return false, err
+	}
+
+	moves, err := carryOver(path, stagingPath, prune)
+	if err == nil {
+		err = swapDir(path, stagingPath)
+	}
+	if err != nil {
+		if undoErr := undoMoves(moves); undoErr != nil {
+			// The staging directory holds entries which couldn't be moved back, so it mustn't be removed.
+			keepStaging = true
+			return false, fmt.Errorf("%w (restoring existing files from %s failed: %v)", err, stagingPath, undoErr)
+		}
+		var aside *moveAsideError
+		if errors.As(err, &aside) && cannotRename(aside.Err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := os.RemoveAll(stagingPath + ".old"); err != nil {
+		return true, fmt.Errorf("remove previous directory: %w", err)
+	}
+	return true, nil
+}
+
+// replaceEntries writes to a staging directory within the existing directory at path, then swaps the entries of the
+// two directories, for directories which can't be replaced as a whole.
+func replaceEntries(path string, prune bool, write func(stagingPath string) error) (err error) {
+	stagingPath, err := os.MkdirTemp(path, ".splitschema-staging-")
+	if err != nil {
+		return fmt.Errorf("create staging directory: %w", err)
+	}
+	keepStaging := false
+	defer func() {
+		if err != nil && !keepStaging {
+			_ = os.RemoveAll(stagingPath)
+		}
+	}()
+	if err := write(stagingPath); err != nil {
+		return err
+	}
+
+	moves, err := carryOver(path, stagingPath, prune)
+	if err == nil {
+		err = swapEntries(path, stagingPath)
+	}
+	if err != nil {
+		if undoErr := undoMoves(moves); undoErr != nil {
+			keepStaging = true
+			return fmt.Errorf("%w (restoring existing files from %s failed: %v)", err, stagingPath, undoErr)
+		}
+		return err
+	}
+	if err := os.RemoveAll(stagingPath + ".old"); err != nil {
+		return fmt.Errorf("remove previous entries: %w", err)
+	}
+	return os.Remove(stagingPath)
+}
+
+// cannotRename reports whether err means a directory can't be renamed or given a sibling, rather than that writing
+// failed, e.g. because it's a mount point (EBUSY), the parent is on another device (EXDEV) or the parent isn't
+// writable.
+func cannotRename(err error) bool {
+	return errors.Is(err, syscall.EBUSY) || errors.Is(err, syscall.EXDEV) || errors.Is(err, fs.ErrPermission)
+}
+
+// move is an entry moved from the existing directory into the staging directory.
+type move struct {
+	from, to string
+}
+
+// carryOver moves the entries of the existing directory which weren't written to the staging directory into it, so
+// they survive the swap without being copied. Directories which were also written are merged, keeping the mode of the
+// existing directory. When prune is set, files recorded by the existing directory's index files are left behind to be
+// removed along with it. It returns the moves made so far, even on error, so they can be undone.
+func carryOver(existingPath, stagingPath string, prune bool) ([]move, error) {
+	c := carrier{existingPath: existingPath, stagingPath: stagingPath}
+	if filepath.Dir(stagingPath) == existingPath {
+		// The staging directory is inside the existing directory when it's staged in place.
+		c.skip = filepath.Base(stagingPath)
+	}
+	if prune {
+		c.prune = indexedLayoutFiles(os.DirFS(existingPath), ".")
+	}
+	err := c.carryDir(".")
+	return c.moves, err
+}
+
+type carrier struct {
+	existingPath string
+	stagingPath  string
+	// prune holds the files to leave behind, or nil to carry over every entry which wasn't written.
+	prune *layoutFileSet
+	// skip is the name of an entry at the root of the existing directory which is never carried over.
+	skip  string
+	moves []move
+}
+
+func (c *carrier) carryDir(relDir string) error {
+	entries, err := os.ReadDir(filepath.Join(c.existingPath, filepath.FromSlash(relDir)))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if relDir == "." && entry.Name() == c.skip {
+			continue
+		}
+		relPath := path.Join(relDir, entry.Name())
+		existingEntry := filepath.Join(c.existingPath, filepath.FromSlash(relPath))
+		stagedEntry := filepath.Join(c.stagingPath, filepath.FromSlash(relPath))
+		stagedInfo, err := os.Lstat(stagedEntry)
+		staged := err == nil
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		if entry.IsDir() && ((staged && stagedInfo.IsDir()) || (c.prune != nil && c.prune.containsWithin(relPath))) {
+			if err := c.carryDir(relPath); err != nil {
+				return err
+			}
+			if staged {
+				if err := c.copyMode(relPath); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if staged || (c.prune != nil && c.prune.contains(relPath)) {
+			continue
+		}
+		if err := c.mkdir(relDir); err != nil {
+			return err
+		}
+		if err := os.Rename(existingEntry, stagedEntry); err != nil {
+			return err
+		}
+		c.moves = append(c.moves, move{from: existingEntry, to: stagedEntry})
+	}
+	return nil
+}
+
+// mkdir creates a directory and its parents in the staging directory if they weren't written, with the modes of the
+// existing directories.
+func (c *carrier) mkdir(relDir string) error {
+	if relDir == "." {
+		return nil
+	}
+	if _, err := os.Lstat(filepath.Join(c.stagingPath, filepath.FromSlash(relDir))); err == nil {
+		return nil
+	}
+	if err := c.mkdir(path.Dir(relDir)); err != nil {
+		return err
+	}
+	if err := os.Mkdir(filepath.Join(c.stagingPath, filepath.FromSlash(relDir)), 0755); err != nil {
+		return err
+	}
+	return c.copyMode(relDir)
+}
+
+// copyMode sets the mode of a staged directory to the mode of the existing directory.
+func (c *carrier) copyMode(relDir string) error {
+	info, err := os.Stat(filepath.Join(c.existingPath, filepath.FromSlash(relDir)))
+	if err != nil {
+		return err
+	}
+	return os.Chmod(filepath.Join(c.stagingPath, filepath.FromSlash(relDir)), info.Mode().Perm())
+}
+
+// undoMoves moves carried over entries back into the existing directory, in reverse order.
+func undoMoves(moves []move) error {
+	var errs []error
+	for i := len(moves) - 1; i >= 0; i-- {
+		if err := os.Rename(moves[i].to, moves[i].from); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// renameDir renames the existing directory aside in swapDir. Tests replace it to simulate a mount point.
+var renameDir = os.Rename
+
+// moveAsideError is returned by swapDir when the existing directory can't be renamed, before anything has changed.
+type moveAsideError struct {
+	Err error
+}
+
+func (e *moveAsideError) Error() string {
+	return fmt.Sprintf("move existing directory aside: %v", e.Err)
+}
+
+func (e *moveAsideError) Unwrap() error {
+	return e.Err
+}
+
+// swapDir replaces the directory at path with the directory at stagingPath, moving the existing directory to
+// stagingPath + ".old". The existing directory is restored if the staged directory can't be moved into place.
+func swapDir(path, stagingPath string) error {
+	backupPath := stagingPath + ".old"
+	if err := renameDir(path, backupPath); err != nil {
+		return &moveAsideError{Err: err}
+	}
+	if err := os.Rename(stagingPath, path); err != nil {
+		if restoreErr := os.Rename(backupPath, path); restoreErr != nil {
+			return fmt.Errorf("move staged directory into place: %w (restoring %s failed: %v)", err, backupPath, restoreErr)
+		}
+		return fmt.Errorf("move staged directory into place: %w", err)
+	}
+	return nil
+}
+
+// swapEntries replaces the entries of the directory at path with the entries of stagingPath, a directory within it,
+// moving the existing entries to stagingPath + ".old" first. The existing entries are restored if any staged entry
+// can't be moved into place. Each entry is swapped separately, so a crash part way through leaves some of the
+// existing entries in the ".old" directory.
+func swapEntries(path, stagingPath string) error {
+	backupPath := stagingPath + ".old"
+	if err := os.Mkdir(backupPath, 0700); err != nil {
+		return fmt.Errorf("create backup directory: %w", err)
+	}
+	existing, err := os.ReadDir(path)
+	if err != nil {
+		return restoreEntries(err, nil, nil, backupPath)
+	}
+	var asides []move
+	for _, entry := range existing {
+		if name := entry.Name(); name != filepath.Base(stagingPath) && name != filepath.Base(backupPath) {
+			m := move{from: filepath.Join(path, name), to: filepath.Join(backupPath, name)}
+			if err := os.Rename(m.from, m.to); err != nil {
+				return restoreEntries(fmt.Errorf("move existing entry aside: %w", err), nil, asides, backupPath)
+			}
+			asides = append(asides, m)
+		}
+	}
+
+	staged, err := os.ReadDir(stagingPath)
+	if err != nil {
+		return restoreEntries(err, nil, asides, backupPath)
+	}
+	var placed []move
+	for _, entry := range staged {
+		m := move{from: filepath.Join(stagingPath, entry.Name()), to: filepath.Join(path, entry.Name())}
+		if err := os.Rename(m.from, m.to); err != nil {
+			return restoreEntries(fmt.Errorf("move staged entry into place: %w", err), placed, asides, backupPath)
+		}
+		placed = append(placed, m)
+	}
+	return nil
+}
+
+// restoreEntries undoes a partial swapEntries, returning err along with any failure to restore the existing entries.
+func restoreEntries(err error, placed, asides []move, backupPath string) error {
+	restoreErr := errors.Join(undoMoves(placed), undoMoves(asides))
+	if restoreErr != nil {
+		return fmt.Errorf("%w (restoring %s failed: %v)", err, backupPath, restoreErr)
+	}
+	_ = os.Remove(backupPath)
+	return err
+}
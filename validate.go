@@ -0,0 +1,185 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	ccmap "github.com/orcaman/concurrent-map/v2"
+)
+
+// ValidationError is returned for a file in a split directory which is inconsistent with the rest of the directory,
+// such as a spec file which isn't referenced by any index.
+type ValidationError struct {
+	// Path is the path of the file, relative to the root of the split directory.
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrCorrupt
+}
+
+// ValidateLocalPackage checks the split directory at path is internally consistent. See ValidatePackage.
+func ValidateLocalPackage(path string, opts ...ReadOption) error {
+	return ValidatePackage(os.DirFS(path), ".", opts...)
+}
+
+// ValidatePackage checks a split directory is internally consistent: every token in the index files has a spec file,
+// every spec, description, metadata, property description and example file is referenced by an index, the core file
+// contains no resources, functions or types, and every file can be decoded. Files are recognised by the shape of the
+// paths written by the manifest's layout, such as `{module}/resources/{name}-{hash}.json`, and by sharing a directory
+// with an indexed token's files, which also covers custom layouts. Other files, such as a README at the root of the
+// directory, are ignored.
+//
+// All problems are reported at once, joined into a single error. Each problem matches ErrCorrupt with errors.Is and is
+// a *SpecFileMissingError, *SpecDecodeError or *ValidationError.
+func ValidatePackage(fsys fs.FS, basePath string, opts ...ReadOption) error {
+	p := newPartialPackage(fsys, basePath, opts...)
+	r, err := p.getReader()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	var problems []error
+
+	var core map[string]any
+	if err := r.readData("core", &core); err != nil {
+		problems = append(problems, indexError(err, r.dataPath("core")))
+	} else {
+		for _, kind := range []string{"resources", "functions", "types"} {
+			if core[kind] != nil {
+				problems = append(problems, &ValidationError{
+					Path:    r.dataPath("core"),
+					Message: fmt.Sprintf("contains %s which belong in %s", kind, r.dataPath(kind)),
+				})
+			}
+		}
+		if _, err := p.getCore(); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	// Files belonging to each token, and directories whose files all belong to a token.
+	referencedFiles := map[string]bool{
+		manifestFileName:        true,
+		r.dataPath("core"):      true,
+		r.dataPath("resources"): true,
+		r.dataPath("functions"): true,
+		r.dataPath("types"):     true,
+	}
+	referencedDirs := map[string]bool{}
+	// Directories the layout wrote spec files into, whose files must all be referenced.
+	layoutDirs := map[string]bool{}
+	for _, kind := range []string{"resources", "functions", "types"} {
+		var mappings *tokenMappings
+		var err error
+		switch kind {
+		case "resources":
+			mappings, err = p.getResourceTokenMappings()
+		case "functions":
+			mappings, err = p.getFunctionTokenMappings()
+		case "types":
+			mappings, err = p.getTypeTokenMappings()
+		}
+		if err != nil {
+			problems = append(problems, indexError(err, r.dataPath(kind)))
+			continue
+		}
+		for _, token := range mappings.list {
+			specPath, err := p.specPath(token, kind)
+			if err != nil {
+				problems = append(problems, err)
+				continue
+			}
+			referencedFiles[r.dataPath(specPath)] = true
+			referencedFiles[specPath+".md"] = true
+			referencedFiles[r.dataPath(specPath+".meta")] = true
+			referencedDirs[specPath+propertiesDirSuffix] = true
+			referencedDirs[specPath+examplesDirSuffix] = true
+			for dir := path.Dir(specPath); dir != "."; dir = path.Dir(dir) {
+				layoutDirs[dir] = true
+			}
+		}
+
+		var specsErr error
+		switch kind {
+		case "resources":
+			_, specsErr = getAll(ctx, mappings.list, p.concurrency, p.GetResourceContext)
+		case "functions":
+			_, specsErr = getAll(ctx, mappings.list, p.concurrency, p.GetFunctionContext)
+		case "types":
+			_, specsErr = getAll(ctx, mappings.list, p.concurrency, p.GetTypeContext)
+		}
+		problems = appendJoined(problems, specsErr)
+
+		metadata := ccmap.New[*any]()
+		_, metadataErr := getAll(ctx, mappings.list, p.concurrency, func(_ context.Context, token string) (*any, error) {
+			return getMetadata(&metadata, &p, kind, token)
+		})
+		problems = appendJoined(problems, metadataErr)
+	}
+
+	err = fs.WalkDir(fsys, basePath, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(basePath, filePath)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if referencedFiles[relPath] {
+			return nil
+		}
+		checked := isRootLayoutFile(relPath) || isLayoutShapedFile(r.layout, relPath)
+		for dir := path.Dir(relPath); dir != "."; dir = path.Dir(dir) {
+			if referencedDirs[dir] {
+				return nil
+			}
+			checked = checked || layoutDirs[dir]
+		}
+		if !checked {
+			return nil
+		}
+		problems = append(problems, &ValidationError{Path: relPath, Message: "not referenced by any index"})
+		return nil
+	})
+	if err != nil {
+		problems = append(problems, err)
+	}
+
+	return errors.Join(problems...)
+}
+
+// indexError describes an error reading a file which isn't specific to a token.
+func indexError(err error, path string) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return &ValidationError{Path: path, Message: "missing"}
+	}
+	return err
+}
+
+// appendJoined appends each of the errors joined in err, so problems from loading many specs are listed individually.
+func appendJoined(errs []error, err error) []error {
+	if err == nil {
+		return errs
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			errs = appendJoined(errs, err)
+		}
+		return errs
+	}
+	return append(errs, err)
+}
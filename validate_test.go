@@ -0,0 +1,181 @@
+// Copyright 2024, Pulumi Corporation.
+
+package splitschema_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
+	"github.com/pulumi/splitschema"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePackage(t *testing.T) {
+	pkg := &schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:index:Resource": {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Line one\nLine two"}},
+		},
+		Functions: map[string]schema.FunctionSpec{
+			"test:index:getResource": {},
+		},
+		Types: map[string]schema.ComplexTypeSpec{
+			"test:index:Type": {ObjectTypeSpec: schema.ObjectTypeSpec{Type: "object"}},
+		},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpec(dir, pkg, splitschema.WriteOptionPathLayout(splitschema.FlatLayout)))
+	require.NoError(t, splitschema.ValidateLocalPackage(dir))
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "types", "test%3Aindex%3AType.json")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "functions", "test%3Aindex%3AgetResource.json"), []byte("{"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "resources", "test%3Aindex%3AStray.md"), []byte("Stray\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "core.json"), []byte(`{"name":"test","resources":{}}`), 0o644))
+
+	err := splitschema.ValidateLocalPackage(dir)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, splitschema.ErrCorrupt)
+
+	var problems []string
+	for _, problem := range err.(interface{ Unwrap() []error }).Unwrap() {
+		assert.ErrorIs(t, problem, splitschema.ErrCorrupt)
+		problems = append(problems, problem.Error())
+	}
+	assert.Len(t, problems, 4)
+	assert.Contains(t, problems, "core.json: contains resources which belong in resources.json")
+	assert.Contains(t, problems, "resources/test%3Aindex%3AStray.md: not referenced by any index")
+
+	var missing *splitschema.SpecFileMissingError
+	assert.True(t, errors.As(err, &missing))
+	var decode *splitschema.SpecDecodeError
+	assert.True(t, errors.As(err, &decode))
+}
+
+func TestValidatePackageMetadataOnly(t *testing.T) {
+	pkg := &schema.PackageSpec{
+		Name:      "test",
+		Resources: map[string]schema.ResourceSpec{"test:index:Resource": {}},
+	}
+	metadata := &splitschema.PackageMetadata{
+		Resources: map[string]any{"test:index:Resource": "meta", "test:index:MetaOnly": "meta"},
+		Functions: map[string]any{"test:index:getMetaOnly": "meta"},
+	}
+	dir := t.TempDir()
+	require.NoError(t, splitschema.WritePackageSpecWithMetadata(dir, pkg, metadata))
+	require.NoError(t, splitschema.ValidateLocalPackage(dir))
+
+	// Without its metadata, the token's spec is missing.
+	require.NoError(t, os.Remove(filepath.Join(dir, "index", "resources", "metaonly-0ad72afb.meta.json")))
+	err := splitschema.ValidateLocalPackage(dir)
+	var missing *splitschema.SpecFileMissingError
+	require.ErrorAs(t, err, &missing)
+	assert.Equal(t, "test:index:MetaOnly", missing.Token)
+}
+
+// prefixLayout is a custom layout writing every file below a single directory without a kind directory.
+type prefixLayout struct{}
+
+func (prefixLayout) Name() string { return "test-prefix" }
+
+func (prefixLayout) Path(token, kind string) (string, error) {
+	return "specs/" + kind[:1] + "-" + strings.ReplaceAll(token, ":", "_"), nil
+}
+
+func (l prefixLayout) DisambiguatedPath(token, kind string) (string, error) {
+	path, err := l.Path(token, kind)
+	return path + "-" + shortHash(token), err
+}
+
+func TestValidatePackageStrayFiles(t *testing.T) {
+	pkg := &schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:ec2/instance:Instance": {},
+		},
+	}
+	tests := []struct {
+		layout splitschema.PathLayout
+		stray  string
+	}{
+		{splitschema.HashedLayout, "ec2/stray.json"},
+		{splitschema.ModuleShardedLayout, "ec2/resources/stray.md"},
+		{prefixLayout{}, "specs/stray.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.layout.Name(), func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, splitschema.WritePackageSpec(dir, pkg, splitschema.WriteOptionPathLayout(tt.layout)))
+			for _, userFile := range []string{"README.md", "docs/types/notes.md"} {
+				require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, userFile)), 0o755))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, userFile), []byte("notes\n"), 0o644))
+			}
+			require.NoError(t, splitschema.ValidateLocalPackage(dir))
+
+			require.NoError(t, os.WriteFile(filepath.Join(dir, filepath.FromSlash(tt.stray)), []byte("{}\n"), 0o644))
+			err := splitschema.ValidateLocalPackage(dir)
+			assert.EqualError(t, err, tt.stray+": not referenced by any index")
+		})
+	}
+}
+
+func TestValidatePackageRemovedModule(t *testing.T) {
+	pkg := &schema.PackageSpec{
+		Name: "test",
+		Resources: map[string]schema.ResourceSpec{
+			"test:ec2/instance:Instance": {},
+			"test:s3/bucket:Bucket":      {ObjectTypeSpec: schema.ObjectTypeSpec{Description: "Line one\nLine two"}},
+		},
+		Functions: map[string]schema.FunctionSpec{"test:s3/getBucket:getBucket": {}},
+		Types:     map[string]schema.ComplexTypeSpec{"test:s3/BucketGrant:BucketGrant": {ObjectTypeSpec: schema.ObjectTypeSpec{Type: "object"}}},
+	}
+	layouts := []splitschema.PathLayout{
+		splitschema.HashedLayout, splitschema.NestedModulesLayout, splitschema.ModuleShardedLayout, splitschema.FlatLayout,
+	}
+	for _, layout := range layouts {
+		t.Run(layout.Name(), func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, splitschema.WritePackageSpec(dir, pkg, splitschema.WriteOptionPathLayout(layout)))
+			for _, userFile := range []string{"README.md", "docs/types/notes.md"} {
+				require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, userFile)), 0o755))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, userFile), []byte("notes\n"), 0o644))
+			}
+			require.NoError(t, splitschema.ValidateLocalPackage(dir))
+
+			// Remove the s3 module from the indexes, leaving its files behind with no indexed token beside them.
+			var expected []string
+			for _, kind := range []string{"resources", "functions", "types"} {
+				indexPath := filepath.Join(dir, kind+".json")
+				indexBytes, err := os.ReadFile(indexPath)
+				require.NoError(t, err)
+				var index map[string]string
+				require.NoError(t, json.Unmarshal(indexBytes, &index))
+				for token, specPath := range index {
+					if strings.HasPrefix(token, "test:s3/") {
+						delete(index, token)
+						expected = append(expected, specPath+".json: not referenced by any index")
+						if token == "test:s3/bucket:Bucket" {
+							expected = append(expected, specPath+".md: not referenced by any index")
+						}
+					}
+				}
+				indexBytes, err = json.Marshal(index)
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(indexPath, indexBytes, 0o644))
+			}
+
+			err := splitschema.ValidateLocalPackage(dir)
+			require.Error(t, err)
+			var problems []string
+			for _, problem := range err.(interface{ Unwrap() []error }).Unwrap() {
+				problems = append(problems, problem.Error())
+			}
+			assert.ElementsMatch(t, expected, problems)
+		})
+	}
+}
@@ -8,10 +8,11 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/pulumi/pulumi/pkg/v3/codegen/schema"
-	yaml "gopkg.in/yaml.v3"
 )
 
 func WritePackageSpec(path string, pkg *schema.PackageSpec, opts ...WriteOption) error {
@@ -28,6 +29,13 @@ func WritePackageSpecWithTypedMetadata[Resource, Function, Type any](path string
 		opt.Apply(options)
 	}
 
+	// Write to a staging directory first so a failure part way through leaves the destination untouched.
+	return writeStaged(path, options.Prune, func(stagingPath string) error {
+		return writePackageSpec(stagingPath, pkg, metadata, options)
+	})
+}
+
+func writePackageSpec[Resource, Function, Type any](path string, pkg *schema.PackageSpec, metadata *TypedPackageMetadata[Resource, Function, Type], options *WriteOptions) error {
 	pkgCopy := *pkg
 	functions := pkg.Functions
 	pkgCopy.Functions = nil
@@ -40,18 +48,82 @@ func WritePackageSpecWithTypedMetadata[Resource, Function, Type any](path string
 	if options.Compact {
 		indent = ""
 	}
-	writer := NewWriter(path, "json", indent)
+	format := options.Format
+	if format == "" {
+		format = "json"
+	}
+	layout := options.Layout
+	if layout == nil {
+		layout = HashedLayout
+	}
+	writer := NewWriter(path, format, indent)
+	writer.layout = layout
+	writer.propertyDescriptions = options.PropertyDescriptions
+	writer.examples = options.Examples
+	if options.Descriptions != nil {
+		writer.descriptions = options.Descriptions
+	}
+	manifest := Manifest{
+		LayoutVersion: writeLayoutVersion(options),
+		Format:        format,
+		HashAlgorithm: "crc32c",
+		Compact:       options.Compact,
+		Layout:        layout.Name(),
+		ToolVersion:   toolVersion(),
+
+		PropertyDescriptions: options.PropertyDescriptions,
+		Examples:             options.Examples,
+	}
+	if err := manifest.validate(); err != nil {
+		return err
+	}
+	if err := writer.WriteManifest(manifest); err != nil {
+		return err
+	}
 	if err := writer.WriteData("core", pkgCopy, ""); err != nil {
 		return err
 	}
 
+	resourcePathTokens := make([]string, 0, len(resources))
+	for token := range resources {
+		resourcePathTokens = append(resourcePathTokens, token)
+	}
+	functionPathTokens := make([]string, 0, len(functions))
+	for token := range functions {
+		functionPathTokens = append(functionPathTokens, token)
+	}
+	typePathTokens := make([]string, 0, len(types))
+	for token := range types {
+		typePathTokens = append(typePathTokens, token)
+	}
+	if metadata != nil {
+		for token := range metadata.Resources {
+			resourcePathTokens = append(resourcePathTokens, token)
+		}
+		for token := range metadata.Functions {
+			functionPathTokens = append(functionPathTokens, token)
+		}
+		for token := range metadata.Types {
+			typePathTokens = append(typePathTokens, token)
+		}
+	}
+	if err := writer.ResolvePaths("resources", resourcePathTokens); err != nil {
+		return err
+	}
+	if err := writer.ResolvePaths("functions", functionPathTokens); err != nil {
+		return err
+	}
+	if err := writer.ResolvePaths("types", typePathTokens); err != nil {
+		return err
+	}
+
 	resourceTokens := make(map[string]string, len(resources))
 	for token, resource := range resources {
 		path, err := writer.WriteResource(token, resource)
 		if err != nil {
 			return err
 		}
-		resourceTokens[token] = path
+		resourceTokens[token] = filepath.ToSlash(path)
 	}
 	if metadata != nil {
 		for token, resourceMetadata := range metadata.Resources {
@@ -59,7 +131,7 @@ func WritePackageSpecWithTypedMetadata[Resource, Function, Type any](path string
 			if err != nil {
 				return err
 			}
-			resourceTokens[token] = path
+			resourceTokens[token] = filepath.ToSlash(path)
 		}
 	}
 	if err := writer.WriteData("resources", resourceTokens, ""); err != nil {
@@ -72,15 +144,15 @@ func WritePackageSpecWithTypedMetadata[Resource, Function, Type any](path string
 		if err != nil {
 			return err
 		}
-		functionTokens[token] = path
+		functionTokens[token] = filepath.ToSlash(path)
 	}
 	if metadata != nil {
 		for token, functionMetadata := range metadata.Functions {
-			path, err := writer.WriteSpec(token, "functions", functionMetadata, "")
+			path, err := writer.WriteMetadata(token, "functions", functionMetadata)
 			if err != nil {
 				return err
 			}
-			functionTokens[token] = path
+			functionTokens[token] = filepath.ToSlash(path)
 		}
 	}
 	if err := writer.WriteData("functions", functionTokens, ""); err != nil {
@@ -93,15 +165,15 @@ func WritePackageSpecWithTypedMetadata[Resource, Function, Type any](path string
 		if err != nil {
 			return err
 		}
-		typeTokens[token] = path
+		typeTokens[token] = filepath.ToSlash(path)
 	}
 	if metadata != nil {
 		for token, typeMetadata := range metadata.Types {
-			path, err := writer.WriteSpec(token, "types", typeMetadata, "")
+			path, err := writer.WriteMetadata(token, "types", typeMetadata)
 			if err != nil {
 				return err
 			}
-			typeTokens[token] = path
+			typeTokens[token] = filepath.ToSlash(path)
 		}
 	}
 	if err := writer.WriteData("types", typeTokens, ""); err != nil {
@@ -117,12 +189,104 @@ func WriteOptionCompact() WriteOption {
 	})
 }
 
+// WriteOptionFormat sets the file format used for the split files. Supported formats are "json" (the default) and "yaml".
+func WriteOptionFormat(format string) WriteOption {
+	return optionFunc(func(opts *WriteOptions) {
+		opts.Format = format
+	})
+}
+
+// WriteOptionPrune removes files from the destination directory which were recorded by its previous index files but
+// weren't written by this write, e.g. files for resources which have since been removed or renamed. Other files are
+// kept.
+func WriteOptionPrune() WriteOption {
+	return optionFunc(func(opts *WriteOptions) {
+		opts.Prune = true
+	})
+}
+
+// WriteOptionNestedModules writes each module to nested directories following the full module path, e.g.
+// `network/v20230201/resources/` for `azure-native:network/v20230201:VirtualNetwork`, rather than only using the first
+// segment of the module. This keeps directories small for providers with many versioned modules.
+// It's equivalent to WriteOptionPathLayout(NestedModulesLayout).
+func WriteOptionNestedModules() WriteOption {
+	return WriteOptionPathLayout(NestedModulesLayout)
+}
+
+// WriteOptionPathLayout sets the layout deciding where the files for each resource, function and type are written.
+// Defaults to HashedLayout.
+func WriteOptionPathLayout(layout PathLayout) WriteOption {
+	return optionFunc(func(opts *WriteOptions) {
+		opts.Layout = layout
+	})
+}
+
+// WriteOptionPropertyDescriptions writes multi-line descriptions of properties to markdown files in a directory next
+// to each spec, e.g. `ec2/resources/instance-539d0ba2.props/inputProperties/userData.md`. Readers restore them into
+// the spec.
+func WriteOptionPropertyDescriptions() WriteOption {
+	return optionFunc(func(opts *WriteOptions) {
+		opts.PropertyDescriptions = true
+	})
+}
+
+// WriteOptionExamples writes the code blocks of examples in markdown descriptions to separate files named after the
+// example and language, e.g. `ec2/resources/instance-539d0ba2.examples/basic-usage.ts`, so they can be reviewed and
+// linted as source files. Readers reassemble the original descriptions.
+func WriteOptionExamples() WriteOption {
+	return optionFunc(func(opts *WriteOptions) {
+		opts.Examples = true
+	})
+}
+
+// WriteOptionDescriptions sets the rule deciding which descriptions are written to separate markdown files rather than
+// kept in the spec. The rule also applies to property descriptions when using WriteOptionPropertyDescriptions.
+// Defaults to MultiLineDescriptions.
+func WriteOptionDescriptions(rule DescriptionRule) WriteOption {
+	return optionFunc(func(opts *WriteOptions) {
+		opts.Descriptions = rule
+	})
+}
+
+// DescriptionRule reports whether a description is written to a separate markdown file. Empty descriptions and
+// descriptions containing carriage returns are always kept in the spec.
+type DescriptionRule func(description string) bool
+
+var (
+	// MultiLineDescriptions writes descriptions containing a newline to markdown files. This is the default.
+	MultiLineDescriptions DescriptionRule = func(description string) bool {
+		return strings.ContainsRune(description, '\n')
+	}
+	// AllDescriptions writes every description to a markdown file.
+	AllDescriptions DescriptionRule = func(string) bool {
+		return true
+	}
+	// NoDescriptions keeps every description in the spec, writing a single file per spec.
+	NoDescriptions DescriptionRule = func(string) bool {
+		return false
+	}
+)
+
+// DescriptionsLongerThan writes descriptions longer than length characters to markdown files.
+func DescriptionsLongerThan(length int) DescriptionRule {
+	return func(description string) bool {
+		return utf8.RuneCountInString(description) > length
+	}
+}
+
 type WriteOption interface {
 	Apply(*WriteOptions)
 }
 
 type WriteOptions struct {
 	Compact bool
+	Format  string
+	Prune   bool
+	Layout  PathLayout
+
+	PropertyDescriptions bool
+	Examples             bool
+	Descriptions         DescriptionRule
 }
 
 type optionFunc func(*WriteOptions)
@@ -143,49 +307,168 @@ type writer struct {
 	basePath string
 	format   string
 	indent   string
+	// layout decides the path of each token's files. Defaults to HashedLayout.
+	layout PathLayout
+	// paths holds the resolved path for each token by kind. Tokens which haven't been resolved use the default path.
+	paths map[string]map[string]string
+	// propertyDescriptions moves multi-line property descriptions to separate markdown files.
+	propertyDescriptions bool
+	// examples moves the code of examples in markdown descriptions to separate files.
+	examples bool
+	// descriptions decides which descriptions are written to markdown files. Defaults to MultiLineDescriptions.
+	descriptions DescriptionRule
 }
 
 func NewWriter(basePath, format, indent string) writer {
-	return writer{basePath: basePath, format: format, indent: indent}
+	return writer{basePath: basePath, format: format, indent: indent, layout: HashedLayout, paths: map[string]map[string]string{}, descriptions: MultiLineDescriptions}
+}
+
+// ResolvePaths assigns a path to each token of a kind. Tokens whose default paths collide (ignoring case, for
+// case-insensitive filesystems) are all given a path with a hash of the whole token instead, so the result doesn't
+// depend on the order the tokens are written in.
+func (w *writer) ResolvePaths(kind string, tokens []string) error {
+	paths := make(map[string]string, len(tokens))
+	tokensByPath := map[string][]string{}
+	for _, token := range tokens {
+		if _, ok := paths[token]; ok {
+			continue
+		}
+		path, err := w.layoutPath(token, kind, w.layout.Path)
+		if err != nil {
+			return err
+		}
+		paths[token] = path
+		key := strings.ToLower(path)
+		tokensByPath[key] = append(tokensByPath[key], token)
+	}
+	for _, colliding := range tokensByPath {
+		if len(colliding) < 2 {
+			continue
+		}
+		for _, token := range colliding {
+			path, err := w.layoutPath(token, kind, w.layout.DisambiguatedPath)
+			if err != nil {
+				return err
+			}
+			paths[token] = path
+		}
+	}
+
+	sortedTokens := make([]string, 0, len(paths))
+	for token := range paths {
+		sortedTokens = append(sortedTokens, token)
+	}
+	slices.Sort(sortedTokens)
+	tokenByPath := make(map[string]string, len(paths))
+	for _, token := range sortedTokens {
+		key := strings.ToLower(paths[token])
+		if other, ok := tokenByPath[key]; ok {
+			return fmt.Errorf("%s %q and %q both map to %s", kind, other, token, paths[token])
+		}
+		tokenByPath[key] = token
+	}
+
+	w.paths[kind] = paths
+	return nil
+}
+
+func (w *writer) path(token, kind string) (string, error) {
+	if path, ok := w.paths[kind][token]; ok {
+		return path, nil
+	}
+	return w.layoutPath(token, kind, w.layout.Path)
+}
+
+// layoutPath returns the path chosen by the layout, checking it can't escape the split directory since layouts may be
+// provided by callers.
+func (w *writer) layoutPath(token, kind string, layoutPath func(token, kind string) (string, error)) (string, error) {
+	path, err := layoutPath(token, kind)
+	if err != nil {
+		return "", err
+	}
+	if err := checkLayoutPath(path); err != nil {
+		return "", fmt.Errorf("layout %q: %s %q: %w", w.layout.Name(), kindName(kind), token, err)
+	}
+	return path, nil
+}
+
+// isMarkdownDescription reports whether a description is written to a separate markdown file. Descriptions containing
+// carriage returns are kept in the spec so markdown files always use LF line endings without changing the description.
+func (w *writer) isMarkdownDescription(description string) bool {
+	if description == "" || strings.ContainsRune(description, '\r') {
+		return false
+	}
+	return w.descriptions(description)
 }
 
 func (w *writer) WriteType(token string, spec schema.ComplexTypeSpec) (string, error) {
 	var markdown string
-	if strings.ContainsRune(spec.Description, '\n') {
+	if w.isMarkdownDescription(spec.Description) {
 		markdown = spec.Description
 		spec.Description = ""
 	}
-	return w.WriteSpec(token, "types", spec, markdown)
+	var props propertyDescriptions
+	if w.propertyDescriptions {
+		props = propertyDescriptions{}
+		spec = props.extractType(spec, w.isMarkdownDescription)
+	}
+	return w.writeSpec(token, "types", spec, markdown, props)
 }
 
 func (w *writer) WriteResource(token string, spec schema.ResourceSpec) (string, error) {
 	var markdown string
-	if strings.ContainsRune(spec.Description, '\n') {
+	if w.isMarkdownDescription(spec.Description) {
 		markdown = spec.Description
 		spec.Description = ""
 	}
-	return w.WriteSpec(token, "resources", spec, markdown)
+	var props propertyDescriptions
+	if w.propertyDescriptions {
+		props = propertyDescriptions{}
+		spec = props.extractResource(spec, w.isMarkdownDescription)
+	}
+	return w.writeSpec(token, "resources", spec, markdown, props)
 }
 
 func (w *writer) WriteFunction(token string, spec schema.FunctionSpec) (string, error) {
 	var markdown string
-	if strings.ContainsRune(spec.Description, '\n') {
+	if w.isMarkdownDescription(spec.Description) {
 		markdown = spec.Description
 		spec.Description = ""
 	}
-	return w.WriteSpec(token, "functions", spec, markdown)
+	var props propertyDescriptions
+	if w.propertyDescriptions {
+		props = propertyDescriptions{}
+		spec = props.extractFunction(spec, w.isMarkdownDescription)
+	}
+	return w.writeSpec(token, "functions", spec, markdown, props)
 }
 
 func (w *writer) WriteSpec(token string, kind string, data any, markdown string) (string, error) {
-	// Handle module names which include type name after "/"
-	path, err := getPath(token, kind)
+	return w.writeSpec(token, kind, data, markdown, nil)
+}
+
+func (w *writer) writeSpec(token string, kind string, data any, markdown string, props propertyDescriptions) (string, error) {
+	path, err := w.path(token, kind)
 	if err != nil {
 		return "", err
 	}
 	if markdown != "" {
-		if err := w.WriteFile(path+".md", []byte(markdown)); err != nil {
+		var examples map[string]string
+		if w.examples {
+			markdown, examples = extractExamples(markdown)
+		}
+		// Always end with a newline for POSIX-friendly text files. Readers remove it again.
+		if err := w.WriteFile(path+".md", []byte(markdown+"\n")); err != nil {
 			return "", err
 		}
+		for name, code := range examples {
+			if err := w.WriteFile(filepath.Join(path+examplesDirSuffix, name), []byte(code)); err != nil {
+				return "", err
+			}
+		}
+	}
+	if err := w.writePropertyDescriptions(path, props); err != nil {
+		return "", err
 	}
 
 	if err := w.WriteData(path, data, "        "); err != nil {
@@ -195,8 +478,7 @@ func (w *writer) WriteSpec(token string, kind string, data any, markdown string)
 }
 
 func (w *writer) WriteMetadata(token string, kind string, data any) (string, error) {
-	// Handle module names which include type name after "/"
-	path, err := getPath(token, kind)
+	path, err := w.path(token, kind)
 	if err != nil {
 		return "", err
 	}
@@ -207,6 +489,14 @@ func (w *writer) WriteMetadata(token string, kind string, data any) (string, err
 	return path, nil
 }
 
+func (w *writer) WriteManifest(manifest Manifest) error {
+	bytes, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return err
+	}
+	return w.WriteFile(manifestFileName, append(bytes, '\n'))
+}
+
 func (w *writer) WriteData(pathExExt string, data any, prefix string) error {
 	var bytes []byte
 	var err error
@@ -220,13 +510,16 @@ func (w *writer) WriteData(pathExExt string, data any, prefix string) error {
 		}
 	} else if w.format == "yaml" {
 		path = pathExExt + ".yaml"
-		bytes, err = yaml.Marshal(data)
+		bytes, err = marshalYAML(data, len(w.indent))
 	} else {
 		return fmt.Errorf("unsupported format: %s", w.format)
 	}
 	if err != nil {
 		return err
 	}
+	if len(bytes) == 0 || bytes[len(bytes)-1] != '\n' {
+		bytes = append(bytes, '\n')
+	}
 	return w.WriteFile(path, bytes)
 }
 